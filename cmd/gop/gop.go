@@ -16,9 +16,80 @@
 
 package main
 
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/qiniu/goplus-dt/hq"
+	"github.com/qiniu/goplus-dt/rules"
+)
+
 // -----------------------------------------------------------------------------
 
+const usage = `Usage:
+
+	gop scrape [-o output] [-check] <rules-file>
+`
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch cmd := os.Args[1]; cmd {
+	case "scrape":
+		scrape(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "gop: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func scrape(args []string) {
+	flags := flag.NewFlagSet("scrape", flag.ExitOnError)
+	output := flags.String("o", "", "output file (overrides output.file of the rules)")
+	check := flags.Bool("check", false, "validate the rules only")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage, "\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	r, err := rules.Load(flags.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	if *check {
+		return
+	}
+	var rows int
+	if *output != "" {
+		var f *os.File
+		if f, err = os.Create(*output); err != nil {
+			fatal(err)
+		}
+		rows, err = r.Run(hq.Source, f)
+		if e := f.Close(); err == nil { // f is closed before fatal, which calls os.Exit
+			err = e
+		}
+	} else {
+		rows, err = r.Run(hq.Source, nil)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "gop scrape: %d rows\n", rows)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "gop:", err)
+	os.Exit(1)
 }
 
 // -----------------------------------------------------------------------------
@@ -0,0 +1,296 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qiniu/goplus-dt/hq"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// -----------------------------------------------------------------------------
+
+// Page - a crawled page.
+type Page struct {
+	URL   string
+	Depth int        // 0 for seeds
+	Doc   hq.NodeSet // Doc.Err is set if fetching failed
+	Links []string   // discovered links, handler can modify it to control what to follow
+}
+
+// Handler handles a crawled page. It is called concurrently by workers.
+type Handler func(page *Page)
+
+// Config - crawler config.
+type Config struct {
+	Concurrency int                   // number of workers, 1 if not set
+	MaxDepth    int                   // max link depth from seeds, 0 means no limit, SeedsOnly fetches seeds only
+	MaxPages    int                   // max pages to fetch, 0 means no limit
+	HostDelay   time.Duration         // min interval between two requests to the same host
+	Filter      func(u *url.URL) bool // discovered links are followed only if Filter returns true
+	Client      *http.Client          // http.DefaultClient if not set
+	Source      hq.SourceCreator      // source to fetch pages
+}
+
+// SeedsOnly - Config.MaxDepth to fetch seeds only, without following links.
+const SeedsOnly = -1
+
+// Stats - crawler stats.
+type Stats struct {
+	Queued  int64 // urls put into frontier, excluding ones dropped when ctx is done
+	Fetched int64 // pages fetched successfully
+	Failed  int64 // pages failed to fetch
+	Dup     int64 // discovered urls dropped as duplicated
+}
+
+// Crawler - a concurrent crawler.
+type Crawler struct {
+	cfg     Config
+	handler Handler
+
+	mutex    sync.Mutex
+	hostNext map[string]time.Time
+
+	stats Stats
+}
+
+// New creates a crawler.
+func New(cfg *Config, handler Handler) *Crawler {
+	c := &Crawler{handler: handler, hostNext: make(map[string]time.Time)}
+	if cfg != nil {
+		c.cfg = *cfg
+	}
+	if c.cfg.Concurrency <= 0 {
+		c.cfg.Concurrency = 1
+	}
+	return c
+}
+
+// Stats returns a snapshot of crawler stats.
+func (p *Crawler) Stats() Stats {
+	return Stats{
+		Queued:  atomic.LoadInt64(&p.stats.Queued),
+		Fetched: atomic.LoadInt64(&p.stats.Fetched),
+		Failed:  atomic.LoadInt64(&p.stats.Failed),
+		Dup:     atomic.LoadInt64(&p.stats.Dup),
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+type task struct {
+	url   string
+	depth int
+}
+
+// Run crawls from seeds until the frontier is drained, MaxPages is reached,
+// or ctx is done. When ctx is done, no more pages are requested, and Run
+// returns ctx.Err() after all in-flight pages are handled.
+func (p *Crawler) Run(ctx context.Context, seeds ...string) error {
+	tasks := make(chan task)
+	done := make(chan *Page)
+	var wg sync.WaitGroup
+	for i := 0; i < p.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				done <- p.visit(ctx, t)
+			}
+		}()
+	}
+
+	var frontier []task
+	seen := make(map[string]bool)
+	push := func(rawurl string, depth int) {
+		u, err := Normalize(rawurl)
+		if err != nil {
+			return
+		}
+		if seen[u] {
+			atomic.AddInt64(&p.stats.Dup, 1)
+			return
+		}
+		if p.cfg.MaxPages > 0 && len(seen) >= p.cfg.MaxPages {
+			return // never dispatched
+		}
+		seen[u] = true
+		atomic.AddInt64(&p.stats.Queued, 1)
+		frontier = append(frontier, task{u, depth})
+	}
+	for _, seed := range seeds {
+		push(seed, 0)
+	}
+
+	dispatched, inflight := 0, 0
+	stopped := ctx.Done()
+	for {
+		var out chan task
+		var next task
+		if len(frontier) > 0 && (p.cfg.MaxPages <= 0 || dispatched < p.cfg.MaxPages) {
+			out, next = tasks, frontier[0]
+		} else if inflight == 0 {
+			break
+		}
+		select {
+		case out <- next:
+			frontier = frontier[1:]
+			dispatched++
+			inflight++
+		case page := <-done:
+			inflight--
+			if p.cfg.MaxDepth < 0 || p.cfg.MaxDepth > 0 && page.Depth >= p.cfg.MaxDepth {
+				continue
+			}
+			for _, link := range page.Links {
+				push(link, page.Depth+1)
+			}
+		case <-stopped:
+			atomic.AddInt64(&p.stats.Queued, -int64(len(frontier)))
+			frontier, stopped = nil, nil
+		}
+	}
+	close(tasks)
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (p *Crawler) visit(ctx context.Context, t task) *Page {
+	page := &Page{URL: t.url, Depth: t.depth}
+	page.Doc = p.fetch(ctx, t.url)
+	if page.Doc.Err != nil {
+		atomic.AddInt64(&p.stats.Failed, 1)
+	} else {
+		atomic.AddInt64(&p.stats.Fetched, 1)
+		page.Links = p.links(page.Doc)
+	}
+	if p.handler != nil {
+		p.handler(page)
+	}
+	return page
+}
+
+func (p *Crawler) fetch(ctx context.Context, rawurl string) (ret hq.NodeSet) {
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return hq.NodeSet{Err: err}
+	}
+	if err = p.wait(ctx, req.URL.Host); err != nil {
+		return hq.NodeSet{Err: err}
+	}
+	return p.cfg.Source.Do(p.cfg.Client, req.WithContext(ctx))
+}
+
+// wait blocks until it's polite to request host again.
+func (p *Crawler) wait(ctx context.Context, host string) error {
+	if p.cfg.HostDelay <= 0 {
+		return nil
+	}
+	now := time.Now()
+	p.mutex.Lock()
+	at := p.hostNext[host]
+	if at.Before(now) {
+		at = now
+	}
+	p.hostNext[host] = at.Add(p.cfg.HostDelay)
+	p.mutex.Unlock()
+	if d := at.Sub(now); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (p *Crawler) links(doc hq.NodeSet) (links []string) {
+	doc.Any().Match(func(node *html.Node) bool {
+		return node.DataAtom == atom.A || node.DataAtom == atom.Area
+	}).ForEach(func(node hq.NodeSet) {
+		href, err := node.HrefVal()
+		if err != nil {
+			return
+		}
+		n, _ := node.CollectOne()
+		link := hq.ResolveURL(n, strings.TrimSpace(href))
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		if p.cfg.Filter != nil && !p.cfg.Filter(u) {
+			return
+		}
+		links = append(links, link)
+	})
+	return
+}
+
+// -----------------------------------------------------------------------------
+
+// Normalize normalizes a url for deduplication: scheme and host are lowercased,
+// default port and fragment are removed, empty path becomes "/", and query
+// parameters are sorted.
+func Normalize(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	switch {
+	case u.Scheme == "http" && strings.HasSuffix(u.Host, ":80"):
+		u.Host = u.Host[:len(u.Host)-3]
+	case u.Scheme == "https" && strings.HasSuffix(u.Host, ":443"):
+		u.Host = u.Host[:len(u.Host)-4]
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		if q, err := url.ParseQuery(u.RawQuery); err == nil {
+			u.RawQuery = q.Encode()
+		}
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String(), nil
+}
+
+// SameHost returns a Config.Filter which only follows links to hosts of seeds.
+func SameHost(seeds ...string) func(u *url.URL) bool {
+	hosts := make(map[string]bool)
+	for _, seed := range seeds {
+		if u, err := url.Parse(seed); err == nil {
+			hosts[strings.ToLower(u.Host)] = true
+		}
+	}
+	return func(u *url.URL) bool {
+		return hosts[strings.ToLower(u.Host)]
+	}
+}
+
+// -----------------------------------------------------------------------------
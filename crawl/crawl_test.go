@@ -0,0 +1,154 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSite serves pages /p0 .. /p9. Page n links to pages n+1 and 2n (mod 10),
+// to itself with a fragment, and to an external site and a mailto url. Links
+// to a page differ in order of query parameters, so they are deduplicated by
+// Normalize.
+func newSite() (srv *httptest.Server, fetches *int32) {
+	fetches = new(int32)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(fetches, 1)
+		var n int
+		fmt.Sscanf(r.URL.Path, "/p%d", &n)
+		fmt.Fprintf(w, `<a href="/p%d?a=1&b=2">next</a><a href="p%d?b=2&a=1">double</a><a href="/p%d?b=2&a=1#top">self</a>`+
+			`<a href="http://other.invalid/">external</a><a href="mailto:a@b.c">mail</a>`, (n+1)%10, n*2%10, n)
+	}))
+	return
+}
+
+type visits struct {
+	mutex  sync.Mutex
+	depths map[string]int
+}
+
+func (p *visits) handle(page *Page) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.depths[page.URL]; ok {
+		panic("visited twice: " + page.URL)
+	}
+	p.depths[page.URL] = page.Depth
+}
+
+func crawl(t *testing.T, cfg *Config, seeds ...string) (*visits, Stats) {
+	v := &visits{depths: make(map[string]int)}
+	c := New(cfg, v.handle)
+	if err := c.Run(context.Background(), seeds...); err != nil {
+		t.Fatal(err)
+	}
+	return v, c.Stats()
+}
+
+func TestCrawlDedup(t *testing.T) {
+	srv, fetches := newSite()
+	defer srv.Close()
+
+	v, stats := crawl(t, &Config{Concurrency: 4, Filter: SameHost(srv.URL)}, srv.URL+"/p1?a=1&b=2", srv.URL+"/p1?b=2&a=1#x")
+	if len(v.depths) != 10 || stats.Fetched != 10 || stats.Queued != 10 || *fetches != 10 {
+		t.Fatal(len(v.depths), stats, *fetches)
+	}
+	if stats.Dup == 0 {
+		t.Fatal("no duplicated urls:", stats)
+	}
+	if v.depths[srv.URL+"/p1?a=1&b=2"] != 0 || v.depths[srv.URL+"/p2?a=1&b=2"] != 1 || v.depths[srv.URL+"/p3?a=1&b=2"] != 2 {
+		t.Fatal("depths:", v.depths)
+	}
+}
+
+func TestCrawlDepth(t *testing.T) {
+	srv, _ := newSite()
+	defer srv.Close()
+
+	v, _ := crawl(t, &Config{MaxDepth: SeedsOnly, Filter: SameHost(srv.URL)}, srv.URL+"/p1?a=1&b=2")
+	if len(v.depths) != 1 {
+		t.Fatal("SeedsOnly:", v.depths)
+	}
+	v, _ = crawl(t, &Config{MaxDepth: 1, Concurrency: 2, Filter: SameHost(srv.URL)}, srv.URL+"/p1?a=1&b=2")
+	if len(v.depths) != 2 { // p1 and p2
+		t.Fatal("MaxDepth 1:", v.depths)
+	}
+	for u, depth := range v.depths {
+		if depth > 1 {
+			t.Fatal(u, depth)
+		}
+	}
+}
+
+func TestCrawlMaxPages(t *testing.T) {
+	srv, fetches := newSite()
+	defer srv.Close()
+
+	_, stats := crawl(t, &Config{MaxPages: 4, Concurrency: 3, Filter: SameHost(srv.URL)}, srv.URL+"/p1?a=1&b=2")
+	if stats.Fetched != 4 || stats.Queued != 4 || *fetches != 4 {
+		t.Fatal(stats, *fetches)
+	}
+}
+
+func TestCrawlHostDelay(t *testing.T) {
+	srv, _ := newSite()
+	defer srv.Close()
+
+	const delay = 20 * time.Millisecond
+	start := time.Now()
+	crawl(t, &Config{MaxPages: 3, Concurrency: 3, HostDelay: delay, Filter: SameHost(srv.URL)}, srv.URL+"/p1?a=1&b=2")
+	if d := time.Since(start); d < 2*delay {
+		t.Fatal("3 pages fetched in", d)
+	}
+}
+
+func TestCrawlCancel(t *testing.T) {
+	srv, fetches := newSite()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := New(&Config{Concurrency: 2, HostDelay: 10 * time.Millisecond, Filter: SameHost(srv.URL)}, func(page *Page) {
+		cancel()
+	})
+	if err := c.Run(ctx, srv.URL+"/p1?a=1&b=2"); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if stats := c.Stats(); stats.Fetched+stats.Failed != stats.Queued || *fetches > 2 {
+		t.Fatal(stats, *fetches)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct{ in, out string }{
+		{"HTTP://Example.COM:80?b=2&a=1#x", "http://example.com/?a=1&b=2"},
+		{"https://a.com:443/x", "https://a.com/x"},
+		{"https://a.com:8443/x#", "https://a.com:8443/x"},
+	}
+	for _, c := range cases {
+		if out, err := Normalize(c.in); err != nil || out != c.out {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", c.in, out, err, c.out)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package export
+
+import (
+	"fmt"
+
+	"github.com/qiniu/goplus-dt/hq"
+	"golang.org/x/net/html"
+)
+
+// -----------------------------------------------------------------------------
+
+// Conv converts a node set into a field value.
+type Conv func(ns hq.NodeSet) (v interface{}, err error)
+
+// Text converts a node set by calling NodeSet.Text.
+func Text(ns hq.NodeSet) (v interface{}, err error) {
+	return ns.Text()
+}
+
+// Int converts a node set by calling NodeSet.Int.
+func Int(ns hq.NodeSet) (v interface{}, err error) {
+	return ns.Int()
+}
+
+// UnitedFloat converts a node set by calling NodeSet.UnitedFloat.
+func UnitedFloat(ns hq.NodeSet) (v interface{}, err error) {
+	return ns.UnitedFloat()
+}
+
+// ScanInt returns a Conv which calls NodeSet.ScanInt with format.
+func ScanInt(format string) Conv {
+	return func(ns hq.NodeSet) (interface{}, error) {
+		return ns.ScanInt(format)
+	}
+}
+
+// AttrVal returns a Conv which calls NodeSet.AttrVal with k.
+func AttrVal(k string) Conv {
+	return func(ns hq.NodeSet) (interface{}, error) {
+		return ns.AttrVal(k)
+	}
+}
+
+// Column - a named column extractor.
+type Column struct {
+	Name   string
+	Select func(item hq.NodeSet) hq.NodeSet // nil means the item itself
+	Conv   Conv
+}
+
+// Col creates a column.
+func Col(name string, sel func(item hq.NodeSet) hq.NodeSet, conv Conv) Column {
+	return Column{Name: name, Select: sel, Conv: conv}
+}
+
+// -----------------------------------------------------------------------------
+
+// ErrorPolicy - what to do when a field fails to be extracted.
+type ErrorPolicy int
+
+const (
+	// Fail stops exporting and returns the error.
+	Fail ErrorPolicy = iota
+	// SkipRow skips the row.
+	SkipRow
+	// Null writes null (an empty field for CSV/TSV) as the field value.
+	Null
+)
+
+// FieldError - an error of extracting a field.
+type FieldError struct {
+	Row    int // from 0, index of the item
+	Column string
+	Err    error
+}
+
+func (p *FieldError) Error() string {
+	return fmt.Sprintf("row %d, column %s: %v", p.Row, p.Column, p.Err)
+}
+
+// Unwrap returns the underlying error.
+func (p *FieldError) Unwrap() error {
+	return p.Err
+}
+
+// Options - options of Write.
+type Options struct {
+	Policy   ErrorPolicy
+	NoHeader bool
+	// OnError is called for each field error if Policy isn't Fail.
+	OnError func(err *FieldError)
+}
+
+// Write extracts a row from each item by cols, writes rows into w, and then
+// closes w (even if it fails, so that written rows are flushed and a JSON array
+// is terminated). It returns the number of rows written, and the first error
+// of items (eg. a page of Paginate fails), extracting fields or writing.
+func Write(w RowWriter, items hq.NodeSet, cols []Column, opts *Options) (rows int, err error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.Name
+	}
+	if err = w.WriteHeader(names, !o.NoHeader); err == nil {
+		rows, err = writeRows(w, items, cols, &o)
+	}
+	if e := w.Close(); err == nil {
+		err = e
+	}
+	return
+}
+
+func writeRows(w RowWriter, items hq.NodeSet, cols []Column, o *Options) (rows int, err error) {
+	row := 0
+	vals := make([]interface{}, len(cols))
+	err = items.ForEachNode(func(node *html.Node) error {
+		defer func() { row++ }()
+		item := hq.Nodes(node)
+		for i, col := range cols {
+			ns := item
+			if col.Select != nil {
+				ns = col.Select(item)
+			}
+			v, e := col.Conv(ns)
+			if e != nil {
+				ferr := &FieldError{Row: row, Column: col.Name, Err: e}
+				if o.Policy == Fail {
+					return ferr
+				}
+				if o.OnError != nil {
+					o.OnError(ferr)
+				}
+				if o.Policy == SkipRow {
+					return nil
+				}
+				v = nil
+			}
+			vals[i] = v
+		}
+		if err := w.WriteRow(vals); err != nil {
+			return err
+		}
+		rows++
+		return nil
+	})
+	return
+}
+
+// -----------------------------------------------------------------------------
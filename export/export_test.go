@@ -0,0 +1,157 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/goplus-dt/hq"
+)
+
+const listPage = `<ul>
+<li><b>apple</b><i>3</i></li>
+<li><b>pear, "green"</b><i>x</i></li>
+<li><b>plum</b><i>5</i></li>
+</ul>`
+
+func listCols() []Column {
+	return []Column{
+		Col("name", func(item hq.NodeSet) hq.NodeSet { return item.Child().B() }, Text),
+		Col("n", func(item hq.NodeSet) hq.NodeSet { return item.Child().I() }, Int),
+	}
+}
+
+func listItems() hq.NodeSet {
+	return hq.Source.String(listPage).Any().Li()
+}
+
+func TestWriters(t *testing.T) {
+	cases := []struct {
+		name string
+		new  func(b *strings.Builder) RowWriter
+		want string
+	}{
+		{"csv", func(b *strings.Builder) RowWriter { return NewCSVWriter(b) },
+			"name,n\napple,3\n\"pear, \"\"green\"\"\",\nplum,5\n"},
+		{"tsv", func(b *strings.Builder) RowWriter { return NewTSVWriter(b) },
+			"name\tn\napple\t3\n\"pear, \"\"green\"\"\"\t\nplum\t5\n"},
+		{"jsonl", func(b *strings.Builder) RowWriter { return NewJSONLinesWriter(b) },
+			`{"name":"apple","n":3}` + "\n" + `{"name":"pear, \"green\"","n":null}` + "\n" + `{"name":"plum","n":5}` + "\n"},
+		{"json", func(b *strings.Builder) RowWriter { return NewJSONArrayWriter(b) },
+			`[{"name":"apple","n":3},{"name":"pear, \"green\"","n":null},{"name":"plum","n":5}]` + "\n"},
+	}
+	for _, c := range cases {
+		var b strings.Builder
+		rows, err := Write(c.new(&b), listItems(), listCols(), &Options{Policy: Null})
+		if err != nil || rows != 3 {
+			t.Fatal(c.name, rows, err)
+		}
+		if b.String() != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, b.String(), c.want)
+		}
+	}
+
+	var b strings.Builder
+	if _, err := Write(NewCSVWriter(&b), listItems(), listCols(), &Options{Policy: SkipRow, NoHeader: true}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "apple,3\nplum,5\n" {
+		t.Fatal("NoHeader:", b.String())
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	var b strings.Builder
+	rows, err := Write(NewJSONArrayWriter(&b), listItems(), listCols(), nil)
+	var ferr *FieldError
+	if !errors.As(err, &ferr) || ferr.Row != 1 || ferr.Column != "n" || rows != 1 {
+		t.Fatal("Fail:", rows, err)
+	}
+	if b.String() != `[{"name":"apple","n":3}]`+"\n" {
+		t.Fatal("Fail: output isn't closed:", b.String())
+	}
+
+	var errs []*FieldError
+	onError := func(err *FieldError) { errs = append(errs, err) }
+	b.Reset()
+	rows, err = Write(NewCSVWriter(&b), listItems(), listCols(), &Options{Policy: SkipRow, OnError: onError})
+	if err != nil || rows != 2 || len(errs) != 1 || errs[0].Row != 1 {
+		t.Fatal("SkipRow:", rows, err, errs)
+	}
+	if b.String() != "name,n\napple,3\nplum,5\n" {
+		t.Fatal("SkipRow:", b.String())
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	var b strings.Builder
+	errItems := errors.New("items error")
+	rows, err := Write(NewJSONArrayWriter(&b), hq.NodeSet{Err: errItems}, listCols(), nil)
+	if err != errItems || rows != 0 || b.String() != "[]\n" {
+		t.Fatal("items.Err:", rows, err, b.String())
+	}
+
+	nan := func(ns hq.NodeSet) (interface{}, error) {
+		if text, _ := ns.Text(); text == "plum" {
+			return math.NaN(), nil
+		}
+		return 1, nil
+	}
+	b.Reset()
+	cols := []Column{Col("name", func(item hq.NodeSet) hq.NodeSet { return item.Child().B() }, nan)}
+	rows, err = Write(NewJSONArrayWriter(&b), listItems(), cols, nil)
+	if err == nil || rows != 2 {
+		t.Fatal("json.Marshal:", rows, err)
+	}
+	var v []map[string]int
+	if err = json.Unmarshal([]byte(b.String()), &v); err != nil || len(v) != 2 {
+		t.Fatal("json.Marshal: invalid output:", b.String(), err)
+	}
+
+	b.Reset()
+	if err = NewJSONArrayWriter(&b).Close(); err != nil || b.String() != "" {
+		t.Fatal("Close without WriteHeader:", b.String(), err)
+	}
+}
+
+func TestWritePageError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, listPage)
+	}))
+	defer srv.Close()
+
+	errNext := errors.New("next page error")
+	next := func(doc hq.NodeSet, page int) (string, error) {
+		return "", errNext
+	}
+	var b strings.Builder
+	items := hq.Source.Paginate(srv.URL, next, nil).Any().Li()
+	rows, err := Write(NewCSVWriter(&b), items, listCols(), &Options{Policy: Null})
+	if err != errNext || rows != 3 {
+		t.Fatal(rows, err)
+	}
+	if strings.Count(b.String(), "\n") != 4 {
+		t.Fatal("rows aren't flushed:", b.String())
+	}
+}
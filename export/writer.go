@@ -0,0 +1,156 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// -----------------------------------------------------------------------------
+
+// RowWriter - a writer of rows.
+type RowWriter interface {
+	// WriteHeader is called once before rows. If header is false, column
+	// names shouldn't be written as a header row.
+	WriteHeader(names []string, header bool) error
+	WriteRow(vals []interface{}) error
+	// Close flushes the output. It doesn't close the underlying io.Writer.
+	Close() error
+}
+
+// -----------------------------------------------------------------------------
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter creates a CSV row writer.
+func NewCSVWriter(w io.Writer) RowWriter {
+	return &csvWriter{csv.NewWriter(w)}
+}
+
+// NewTSVWriter creates a TSV row writer. Fields containing tabs, quotes or
+// newlines are quoted as CSV does.
+func NewTSVWriter(w io.Writer) RowWriter {
+	cw := csv.NewWriter(w)
+	cw.Comma = '\t'
+	return &csvWriter{cw}
+}
+
+func (p *csvWriter) WriteHeader(names []string, header bool) error {
+	if !header {
+		return nil
+	}
+	return p.w.Write(names)
+}
+
+func (p *csvWriter) WriteRow(vals []interface{}) error {
+	record := make([]string, len(vals))
+	for i, v := range vals {
+		if v != nil {
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return p.w.Write(record)
+}
+
+func (p *csvWriter) Close() error {
+	p.w.Flush()
+	return p.w.Error()
+}
+
+// -----------------------------------------------------------------------------
+
+type jsonWriter struct {
+	w      *bufio.Writer
+	keys   [][]byte
+	array  bool
+	opened bool // '[' of the array is written
+	n      int
+}
+
+// NewJSONLinesWriter creates a JSON Lines row writer, which writes each row as
+// a JSON object keyed by column names.
+func NewJSONLinesWriter(w io.Writer) RowWriter {
+	return &jsonWriter{w: bufio.NewWriter(w)}
+}
+
+// NewJSONArrayWriter creates a row writer which writes all rows as a JSON
+// array of objects keyed by column names.
+func NewJSONArrayWriter(w io.Writer) RowWriter {
+	return &jsonWriter{w: bufio.NewWriter(w), array: true}
+}
+
+func (p *jsonWriter) WriteHeader(names []string, header bool) error {
+	p.keys = make([][]byte, len(names))
+	for i, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		p.keys[i] = key
+	}
+	if p.array {
+		if err := p.w.WriteByte('['); err != nil {
+			return err
+		}
+		p.opened = true
+	}
+	return nil
+}
+
+func (p *jsonWriter) WriteRow(vals []interface{}) error {
+	row := make([][]byte, len(vals))
+	for i, v := range vals { // marshal all values first, so that no partial object is written
+		val, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		row[i] = val
+	}
+	if p.array && p.n > 0 {
+		p.w.WriteByte(',')
+	}
+	p.n++
+	p.w.WriteByte('{')
+	for i, val := range row {
+		if i > 0 {
+			p.w.WriteByte(',')
+		}
+		p.w.Write(p.keys[i])
+		p.w.WriteByte(':')
+		p.w.Write(val)
+	}
+	p.w.WriteByte('}')
+	if p.array {
+		return nil
+	}
+	return p.w.WriteByte('\n')
+}
+
+func (p *jsonWriter) Close() error {
+	if p.opened {
+		p.w.WriteString("]\n")
+	}
+	return p.w.Flush()
+}
+
+// -----------------------------------------------------------------------------
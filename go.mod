@@ -0,0 +1,8 @@
+module github.com/qiniu/goplus-dt
+
+go 1.25.0
+
+require (
+	golang.org/x/net v0.57.0
+	gopkg.in/yaml.v3 v3.0.1
+)
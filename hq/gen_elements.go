@@ -0,0 +1,166 @@
+//go:build ignore
+// +build ignore
+
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// This program generates html_elements.go. Run it by `go generate`.
+//
+// Elements are read from package golang.org/x/net/html/atom: the element list
+// and the list of extra names of its generator (gen.go), where the latter also
+// has obsolete elements which are still parsed, like font and center.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html/atom"
+)
+
+// nonElements are names in the extra list of package atom which aren't html
+// elements: attributes, and MathML and SVG elements.
+var nonElements = map[string]bool{
+	"align": true, "annotation": true, "annotation-xml": true, "color": true,
+	"desc": true, "face": true, "foreignObject": true, "foreignobject": true,
+	"malignmark": true, "mglyph": true, "mi": true, "mn": true, "mo": true,
+	"ms": true, "mtext": true, "prompt": true, "public": true, "system": true,
+}
+
+// renamed are elements whose shortcut names conflict with other NodeSet
+// members, so they are suffixed with Element. The value tells the conflict.
+var renamed = map[string]string{
+	"data": "the NodeEnum field of NodeSet",
+	"form": "the method returning the first form model",
+}
+
+// atomLists returns string lists named names in gen.go of package atom.
+func atomLists(names ...string) (ret []string) {
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", "golang.org/x/net/html/atom").Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+	file := filepath.Join(strings.TrimSpace(string(out)), "gen.go")
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || len(spec.Names) != 1 || !wanted[spec.Names[0].Name] || len(spec.Values) != 1 {
+			return true
+		}
+		list, ok := spec.Values[0].(*ast.CompositeLit)
+		if !ok {
+			log.Fatalf("%s: %s isn't a list", file, spec.Names[0].Name)
+		}
+		for _, elt := range list.Elts {
+			lit, ok := elt.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				log.Fatalf("%s: %s isn't a string list", file, spec.Names[0].Name)
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				log.Fatal(err)
+			}
+			ret = append(ret, s)
+		}
+		delete(wanted, spec.Names[0].Name)
+		return false
+	})
+	for name := range wanted {
+		log.Fatalf("%s: %s not found", file, name)
+	}
+	return
+}
+
+func main() {
+	seen := make(map[string]bool)
+	var elements []string
+	for _, tag := range atomLists("elements", "extra") {
+		if seen[tag] || nonElements[tag] || atom.Lookup([]byte(tag)) == 0 {
+			continue
+		}
+		seen[tag] = true
+		elements = append(elements, tag)
+	}
+	sort.Strings(elements)
+	var b bytes.Buffer
+	b.WriteString(`/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Code generated by gen_elements.go; DO NOT EDIT.
+
+package hq
+
+import (
+	"golang.org/x/net/html/atom"
+)
+
+// -----------------------------------------------------------------------------
+`)
+	for _, tag := range elements {
+		name := strings.ToUpper(tag[:1]) + tag[1:]
+		method, doc := name, fmt.Sprintf("// %s returns %s nodes.\n", name, tag)
+		if conflict, ok := renamed[tag]; ok {
+			method = name + "Element"
+			doc = fmt.Sprintf("// %s returns %s nodes.\n// It isn't named %s, which is %s.\n", method, tag, name, conflict)
+		}
+		fmt.Fprintf(&b, `
+%sfunc (p NodeSet) %s() (ret NodeSet) {
+	return p.dataAtom(atom.%s)
+}
+`, doc, method, name)
+	}
+	b.WriteString(`
+// -----------------------------------------------------------------------------
+`)
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = ioutil.WriteFile("html_elements.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
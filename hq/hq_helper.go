@@ -23,6 +23,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -37,12 +38,22 @@ var (
 	ErrInvalidScanFormat = errors.New("invalid fmt.Scan format")
 	// ErrUnmatchedScanFormat - unmatched fmt.Scan format
 	ErrUnmatchedScanFormat = errors.New("unmatched fmt.Scan format")
+	// ErrUnmatchedText - text doesn't match the regular expression
+	ErrUnmatchedText = errors.New("unmatched text")
 )
 
 // -----------------------------------------------------------------------------
 
 // SourceCreator - hq source creator.
-type SourceCreator struct{}
+type SourceCreator struct {
+	// Client is used to send requests of http sources, http.DefaultClient if nil.
+	// Set its Transport to plug in a CacheTransport, for example.
+	Client *http.Client
+
+	// Robots is an opt-in robots.txt policy for http sources. If it isn't nil,
+	// disallowed urls aren't fetched and NodeSet.Err is ErrDisallowedByRobots.
+	Robots *Robots
+}
 
 var (
 	// Source - hq source creator
@@ -66,7 +77,7 @@ func (p SourceCreator) File(htmlFile string) (ret NodeSet) {
 		return NodeSet{Err: err}
 	}
 	defer f.Close()
-	return NewSource(f)
+	return newFileSource(f, htmlFile)
 }
 
 // Bytes - a bytes hq source
@@ -81,6 +92,13 @@ func (p SourceCreator) String(text string) (ret NodeSet) {
 	return NewSource(r)
 }
 
+// Fragment - a html fragment hq source, parsed in the context of element
+// contextTag (eg. "tr" for `<td>1</td>`, "body" if empty). See NewFragmentSource.
+func (p SourceCreator) Fragment(text string, contextTag string) (ret NodeSet) {
+	r := strings.NewReader(text)
+	return NewFragmentSource(r, contextTag)
+}
+
 // URI - a uri hq source
 func (p SourceCreator) URI(uri string) (ret NodeSet) {
 	switch {
@@ -93,33 +111,69 @@ func (p SourceCreator) URI(uri string) (ret NodeSet) {
 
 // HTTP - a http hq source
 func (p SourceCreator) HTTP(url string) (ret NodeSet) {
-	if ret = httpSource(url); ret.Err != nil {
-		ret = httpSource(url)
+	if ret = p.httpSource(url); ret.Err != nil && ret.Err != ErrDisallowedByRobots {
+		ret = p.httpSource(url)
 	}
 	return
 }
 
-func httpSource(url string) (ret NodeSet) {
-	resp, err := http.Get(url)
+func (p SourceCreator) httpSource(url string) (ret NodeSet) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return NodeSet{Err: err}
+	}
+	return p.Do(nil, req)
+}
+
+// Do - a http hq source which sends req by client.
+// If client is nil, p.Client or http.DefaultClient is used.
+func (p SourceCreator) Do(client *http.Client, req *http.Request) (ret NodeSet) {
+	if client == nil {
+		if client = p.Client; client == nil {
+			client = http.DefaultClient
+		}
+	}
+	if p.Robots != nil {
+		if err := p.Robots.Check(req.Context(), req.URL); err != nil {
+			return NodeSet{Err: err}
+		}
+		if p.Robots.UserAgent != "" && req.Header.Get("User-Agent") == "" {
+			req = req.Clone(req.Context()) // req of the caller isn't modified
+			req.Header.Set("User-Agent", p.Robots.UserAgent)
+		}
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return NodeSet{Err: err}
 	}
 	defer resp.Body.Close()
-	return NewSource(resp.Body)
+	return newURLSource(resp.Body, resp.Request.URL.String())
 }
 
 // -----------------------------------------------------------------------------
 
 // Printf prints all nodes.
+// If it fails to render a node, the returned node set's Err is set.
 func (p NodeSet) Printf(w io.Writer, format string, params ...interface{}) NodeSet {
 	if p.Err != nil {
 		return p
 	}
-	p.Data.ForEach(func(node *html.Node) error {
-		html.Render(w, node)
-		fmt.Fprintf(w, format, params...)
+	var err error
+	srcErr := forEachErr(p.Data, func(node *html.Node) error {
+		if err = html.Render(w, node); err != nil {
+			return ErrBreak
+		}
+		if _, err = fmt.Fprintf(w, format, params...); err != nil {
+			return ErrBreak
+		}
 		return nil
 	})
+	if err == nil {
+		err = srcErr
+	}
+	if err != nil {
+		return NodeSet{Err: err}
+	}
 	return p
 }
 
@@ -132,140 +186,109 @@ func (p NodeSet) Dump() NodeSet {
 
 // ChildEqualText returns node set whose child is TextNode and value is equal to text.
 func (p NodeSet) ChildEqualText(text string) (ret NodeSet) {
-	return p.Match(func(node *html.Node) bool {
+	return p.match(func(node *html.Node) bool {
 		return ChildEqualText(node, text)
-	})
+	}, "ChildEqualText", text)
 }
 
 // EqualText returns node set who is TextNode and value is equal to text.
 func (p NodeSet) EqualText(text string) (ret NodeSet) {
-	return p.Match(func(node *html.Node) bool {
+	return p.match(func(node *html.Node) bool {
 		return EqualText(node, text)
-	})
+	}, "EqualText", text)
 }
 
 // ContainsText returns node set who is TextNode and value contains text.
 func (p NodeSet) ContainsText(text string) (ret NodeSet) {
-	return p.Match(func(node *html.Node) bool {
+	return p.match(func(node *html.Node) bool {
 		return ContainsText(node, text)
-	})
+	}, "ContainsText", text)
+}
+
+// MatchText returns node set who is TextNode and normalized value matches re.
+func (p NodeSet) MatchText(re *regexp.Regexp) (ret NodeSet) {
+	return p.match(func(node *html.Node) bool {
+		return MatchText(node, re)
+	}, "MatchText", re)
+}
+
+// ContainsTextFold returns node set who is TextNode and normalized value
+// contains text under case-folding.
+func (p NodeSet) ContainsTextFold(text string) (ret NodeSet) {
+	return p.match(func(node *html.Node) bool {
+		return ContainsTextFold(node, text)
+	}, "ContainsTextFold", text)
 }
 
+// HasText returns nodes whose text (including text of descendants), normalized,
+// contains text. Note ancestors of a matched node also match when used after Any.
+func (p NodeSet) HasText(text string) (ret NodeSet) {
+	return p.match(func(node *html.Node) bool {
+		return HasText(node, text)
+	}, "HasText", text)
+}
+
+//go:generate go run gen_elements.go
+
+// dataAtom is used by element shortcuts (eg. NodeSet.Div) in html_elements.go.
 func (p NodeSet) dataAtom(elem atom.Atom) (ret NodeSet) {
-	return p.Match(func(node *html.Node) bool {
+	return p.matchIndexed(indexKey{indexTag, strings.ToLower(elem.String())}, func(node *html.Node) bool {
 		return node.DataAtom == elem
-	})
+	}, "Element", elem)
 }
 
 // Element returns nodes whose element type is v.
 func (p NodeSet) Element(v interface{}) (ret NodeSet) {
 	switch elem := v.(type) {
 	case string:
-		return p.Match(func(node *html.Node) bool {
+		return p.matchIndexed(indexKey{indexTag, strings.ToLower(elem)}, func(node *html.Node) bool {
 			return node.Type == html.ElementNode && node.Data == elem
-		})
+		}, "Element", elem)
 	case atom.Atom:
-		return p.Match(func(node *html.Node) bool {
-			return node.DataAtom == elem
-		})
+		return p.dataAtom(elem)
 	default:
 		panic("unsupport argument type")
 	}
 }
 
+// Elements returns nodes whose element type is one of tags. A tag is a string
+// or an atom.Atom, as Element accepts.
+func (p NodeSet) Elements(tags ...interface{}) (ret NodeSet) {
+	names := make(map[string]bool)
+	atoms := make(map[atom.Atom]bool)
+	for _, v := range tags {
+		switch elem := v.(type) {
+		case string:
+			names[elem] = true
+		case atom.Atom:
+			atoms[elem] = true
+		default:
+			panic("unsupport argument type")
+		}
+	}
+	return p.match(func(node *html.Node) bool {
+		return node.Type == html.ElementNode && (names[node.Data] || atoms[node.DataAtom])
+	}, "Elements", tags...)
+}
+
 // Attribute returns nodes whose attribute k's value is v.
 func (p NodeSet) Attribute(k, v string) (ret NodeSet) {
-	return p.Match(func(node *html.Node) bool {
-		if node.Type != html.ElementNode {
-			return false
-		}
-		for _, attr := range node.Attr {
-			if attr.Key == k && attr.Val == v {
-				return true
-			}
-		}
-		return false
-	})
+	var key indexKey
+	if strings.EqualFold(k, "id") {
+		key = indexKey{indexID, v}
+	}
+	return p.matchIndexed(key, func(node *html.Node) bool {
+		val, err := AttributeVal(node, k)
+		return err == nil && val == v
+	}, "Attribute", k, v)
 }
 
 // ContainsClass returns nodes whose attribute `class` contains v.
 func (p NodeSet) ContainsClass(v string) (ret NodeSet) {
-	return p.Match(func(node *html.Node) bool {
-		if node.Type != html.ElementNode {
-			return false
-		}
-		for _, attr := range node.Attr {
-			if attr.Key == "class" {
-				return ContainsClass(attr.Val, v)
-			}
-		}
-		return false
-	})
-}
-
-// H1 returns h1 nodes.
-func (p NodeSet) H1() (ret NodeSet) {
-	return p.dataAtom(atom.H1)
-}
-
-// H2 returns h2 nodes.
-func (p NodeSet) H2() (ret NodeSet) {
-	return p.dataAtom(atom.H2)
-}
-
-// H3 returns h3 nodes.
-func (p NodeSet) H3() (ret NodeSet) {
-	return p.dataAtom(atom.H3)
-}
-
-// H4 returns h4 nodes.
-func (p NodeSet) H4() (ret NodeSet) {
-	return p.dataAtom(atom.H4)
-}
-
-// Td returns td nodes.
-func (p NodeSet) Td() (ret NodeSet) {
-	return p.dataAtom(atom.Td)
-}
-
-// A returns a nodes.
-func (p NodeSet) A() (ret NodeSet) {
-	return p.dataAtom(atom.A)
-}
-
-// Img returns img nodes.
-func (p NodeSet) Img() (ret NodeSet) {
-	return p.dataAtom(atom.Img)
-}
-
-// Ol returns ol nodes.
-func (p NodeSet) Ol() (ret NodeSet) {
-	return p.dataAtom(atom.Ol)
-}
-
-// Ul returns ul nodes.
-func (p NodeSet) Ul() (ret NodeSet) {
-	return p.dataAtom(atom.Ul)
-}
-
-// Span returns span nodes.
-func (p NodeSet) Span() (ret NodeSet) {
-	return p.dataAtom(atom.Span)
-}
-
-// Div returns div nodes.
-func (p NodeSet) Div() (ret NodeSet) {
-	return p.dataAtom(atom.Div)
-}
-
-// Nav returns nav nodes.
-func (p NodeSet) Nav() (ret NodeSet) {
-	return p.dataAtom(atom.Nav)
-}
-
-// Li returns li nodes.
-func (p NodeSet) Li() (ret NodeSet) {
-	return p.dataAtom(atom.Li)
+	return p.matchIndexed(indexKey{indexClass, v}, func(node *html.Node) bool {
+		val, err := AttributeVal(node, "class")
+		return err == nil && ContainsClass(val, v)
+	}, "ContainsClass", v)
 }
 
 // Class returns nodes whose whose attribute `class`'s value is v.
@@ -287,31 +310,47 @@ func (p NodeSet) Href(v string) (ret NodeSet) {
 
 // ExactText returns text node's text.
 func (p NodeSet) ExactText(exactlyOne ...bool) (text string, err error) {
-	node, err := p.CollectOne(exactlyOne)
+	node, err := p.CollectOne(exactlyOne...)
 	if err != nil {
 		return
 	}
-	return ExactText(node)
+	if text, err = ExactText(node); err != nil {
+		err = p.nodeError(node, "ExactText", err)
+	}
+	return
 }
 
 // Text returns node's text
 func (p NodeSet) Text(exactlyOne ...bool) (text string, err error) {
-	node, err := p.CollectOne(exactlyOne)
+	node, err := p.CollectOne(exactlyOne...)
 	if err != nil {
 		return
 	}
 	return Text(node), nil
 }
 
+// TextSubmatch gets node's text, normalized, and returns the leftmost match of
+// re and its submatches (see regexp.Regexp.FindStringSubmatch).
+func (p NodeSet) TextSubmatch(re *regexp.Regexp, exactlyOne ...bool) (submatch []string, err error) {
+	node, err := p.CollectOne(exactlyOne...)
+	if err != nil {
+		return
+	}
+	if submatch = re.FindStringSubmatch(NormalizeText(Text(node))); submatch == nil {
+		err = p.nodeError(node, fmt.Sprintf("TextSubmatch(%q)", re), ErrUnmatchedText)
+	}
+	return
+}
+
 // ScanInt gets node's text and scans it to an integer.
 func (p NodeSet) ScanInt(format string, exactlyOne ...bool) (v int, err error) {
-	text, err := p.Text(exactlyOne)
+	node, err := p.CollectOne(exactlyOne...)
 	if err != nil {
 		return
 	}
-	err = fmtSscanf(text, format, &v)
+	err = fmtSscanf(Text(node), format, &v)
 	if err != nil {
-		v = 0
+		v, err = 0, p.nodeError(node, fmt.Sprintf("ScanInt(%q)", format), err)
 	}
 	return
 }
@@ -345,10 +384,17 @@ func parseFormat(format string) (prefix, suffix string, err error) {
 
 // UnitedFloat gets node's text and converts it into a united float.
 func (p NodeSet) UnitedFloat(exactlyOne ...bool) (v float64, err error) {
-	text, err := p.Text(exactlyOne)
+	node, err := p.CollectOne(exactlyOne...)
 	if err != nil {
 		return
 	}
+	if v, err = unitedFloat(Text(node)); err != nil {
+		err = p.nodeError(node, "UnitedFloat", err)
+	}
+	return
+}
+
+func unitedFloat(text string) (v float64, err error) {
 	n := len(text)
 	if n == 0 {
 		return 0, ErrEmptyText
@@ -368,25 +414,31 @@ func (p NodeSet) UnitedFloat(exactlyOne ...bool) (v float64, err error) {
 
 // Int gets node's text and converts it into an integer.
 func (p NodeSet) Int(exactlyOne ...bool) (v int, err error) {
-	text, err := p.Text(exactlyOne)
+	node, err := p.CollectOne(exactlyOne...)
 	if err != nil {
 		return
 	}
-	return strconv.Atoi(strings.Replace(text, ",", "", -1))
+	if v, err = strconv.Atoi(strings.Replace(Text(node), ",", "", -1)); err != nil {
+		err = p.nodeError(node, "Int", err)
+	}
+	return
 }
 
 // AttrVal returns node attriute k's value.
 func (p NodeSet) AttrVal(k string, exactlyOne ...bool) (text string, err error) {
-	node, err := p.CollectOne(exactlyOne)
+	node, err := p.CollectOne(exactlyOne...)
 	if err != nil {
 		return
 	}
-	return AttributeVal(node, k)
+	if text, err = AttributeVal(node, k); err != nil {
+		err = p.nodeError(node, fmt.Sprintf("AttrVal(%q)", k), err)
+	}
+	return
 }
 
 // HrefVal returns node attriute href's value.
 func (p NodeSet) HrefVal(exactlyOne ...bool) (text string, err error) {
-	return p.AttrVal("href", exactlyOne)
+	return p.AttrVal("href", exactlyOne...)
 }
 
 // -----------------------------------------------------------------------------
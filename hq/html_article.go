@@ -0,0 +1,462 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// -----------------------------------------------------------------------------
+
+// Article - main content of a page, see MainContent.
+type Article struct {
+	Title  string
+	Byline string
+	Date   string // as it appears in the page, eg. 2020-06-01T08:00:00Z
+
+	// Node is a div holding copies of the best subtree and its related
+	// siblings, cleaned of scripts, forms and link-heavy blocks. The page
+	// itself isn't modified.
+	Node *html.Node
+}
+
+// Content returns the article content as a node set.
+func (p *Article) Content() NodeSet {
+	return Nodes(p.Node)
+}
+
+// Text returns the article text.
+func (p *Article) Text() string {
+	return Text(p.Node)
+}
+
+// MainContent finds the main content of a page (eg. a news article without
+// navigation, ads and comments) by a readability heuristic: paragraphs are
+// scored by text length and commas, scores propagate to their ancestors, and
+// the ancestor with the best score, weighted by class/id hints and link
+// density, is chosen together with its related siblings.
+func MainContent(ns NodeSet) (ret *Article, err error) {
+	node, err := ns.CollectOne()
+	if err != nil {
+		return
+	}
+	root := node
+	if node.Type == html.DocumentNode {
+		if body := findElement(node, atom.Body); body != nil {
+			root = body
+		}
+	}
+	ret = &Article{
+		Title:  articleTitle(node),
+		Byline: articleByline(node),
+		Date:   articleDate(node),
+	}
+	s := &articleScorer{scores: make(map[*html.Node]float64)}
+	s.scoreParagraphs(root)
+	best := s.best(root)
+	ret.Node = s.merge(best)
+	return
+}
+
+// -----------------------------------------------------------------------------
+
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote`)
+	maybeCandidate     = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveHints      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeHints      = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	bylineHints        = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+)
+
+// junkElements are dropped from the article with their content.
+var junkElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Link: true,
+	atom.Iframe: true, atom.Form: true, atom.Button: true, atom.Input: true,
+	atom.Select: true, atom.Textarea: true, atom.Nav: true, atom.Aside: true,
+	atom.Footer: true, atom.Object: true, atom.Embed: true,
+}
+
+// paragraphElements are scored by their text.
+var paragraphElements = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Td: true, atom.Blockquote: true,
+}
+
+type articleScorer struct {
+	scores     map[*html.Node]float64
+	candidates []*html.Node // in the order of being scored
+}
+
+func classID(node *html.Node) string {
+	return attrOr(node, "class", "") + " " + attrOr(node, "id", "")
+}
+
+// classWeight weights a node by its class and id.
+func classWeight(node *html.Node) (weight float64) {
+	for _, v := range []string{attrOr(node, "class", ""), attrOr(node, "id", "")} {
+		if v == "" {
+			continue
+		}
+		if negativeHints.MatchString(v) {
+			weight -= 25
+		}
+		if positiveHints.MatchString(v) {
+			weight += 25
+		}
+	}
+	return
+}
+
+func isUnlikely(node *html.Node) bool {
+	switch node.DataAtom {
+	case atom.Body, atom.Article, atom.Main, atom.A:
+		return false
+	}
+	v := classID(node)
+	if attrOr(node, "role", "") == "complementary" || attrOr(node, "aria-hidden", "") == "true" {
+		return true
+	}
+	return unlikelyCandidates.MatchString(v) && !maybeCandidate.MatchString(v)
+}
+
+// hasBlockChild checks if node has block elements as children.
+func hasBlockChild(node *html.Node) bool {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && blockElements[child.Data] {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *articleScorer) initScore(node *html.Node) {
+	if _, ok := p.scores[node]; ok {
+		return
+	}
+	score := classWeight(node)
+	switch node.DataAtom {
+	case atom.Div, atom.Article, atom.Main, atom.Section:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+	p.scores[node] = score
+	p.candidates = append(p.candidates, node)
+}
+
+// scoreParagraphs scores paragraphs under node, and adds their scores to
+// their parents (fully), grandparents (a half) and further ancestors.
+func (p *articleScorer) scoreParagraphs(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || junkElements[child.DataAtom] || isUnlikely(child) {
+			continue
+		}
+		if !paragraphElements[child.DataAtom] && (child.DataAtom != atom.Div || hasBlockChild(child)) {
+			p.scoreParagraphs(child)
+			continue
+		}
+		text := innerText(child)
+		if len(text) < 25 {
+			continue
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，"))
+		if n := float64(len(text)) / 100; n < 3 {
+			score += n
+		} else {
+			score += 3
+		}
+		level := 0
+		for anc := child.Parent; anc != nil && anc.Type == html.ElementNode && level < 3; anc = anc.Parent {
+			p.initScore(anc)
+			divider := 1.0
+			if level == 1 {
+				divider = 2
+			} else if level > 1 {
+				divider = float64(level * 3)
+			}
+			p.scores[anc] += score / divider
+			level++
+		}
+	}
+}
+
+// best returns the candidate with the best score weighted by link density.
+func (p *articleScorer) best(root *html.Node) *html.Node {
+	var best *html.Node
+	var bestScore float64
+	for _, node := range p.candidates {
+		score := p.scores[node] * (1 - linkDensity(node))
+		p.scores[node] = score
+		if best == nil || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	if best == nil {
+		return root
+	}
+	return best
+}
+
+// merge copies best and its related siblings into a div, and cleans them.
+func (p *articleScorer) merge(best *html.Node) *html.Node {
+	div := &html.Node{Type: html.ElementNode, DataAtom: atom.Div, Data: "div"}
+	if best.Parent == nil || best.DataAtom == atom.Body {
+		div.AppendChild(p.clean(best))
+		return div
+	}
+	bestScore := p.scores[best]
+	threshold := bestScore * 0.2
+	if threshold < 10 {
+		threshold = 10
+	}
+	bestClass := attrOr(best, "class", "")
+	for sibling := best.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+		related := sibling == best
+		if !related {
+			bonus := 0.0
+			if bestClass != "" && attrOr(sibling, "class", "") == bestClass {
+				bonus = bestScore * 0.2
+			}
+			if score, ok := p.scores[sibling]; ok && score+bonus >= threshold {
+				related = true
+			} else if sibling.DataAtom == atom.P {
+				text := innerText(sibling)
+				density := linkDensity(sibling)
+				related = len(text) > 80 && density < 0.25 ||
+					len(text) > 0 && density == 0 && (strings.Contains(text, ". ") || strings.HasSuffix(text, "."))
+			}
+		}
+		if related {
+			div.AppendChild(p.clean(sibling))
+		}
+	}
+	return div
+}
+
+// clean returns a copy of node without junk elements and link-heavy blocks.
+func (p *articleScorer) clean(node *html.Node) *html.Node {
+	ret := &html.Node{
+		Type: node.Type, DataAtom: node.DataAtom, Data: node.Data, Namespace: node.Namespace,
+		Attr: append([]html.Attribute(nil), node.Attr...),
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.CommentNode || child.Type == html.ElementNode && p.isJunk(child) {
+			continue
+		}
+		ret.AppendChild(p.clean(child))
+	}
+	return ret
+}
+
+func (p *articleScorer) isJunk(node *html.Node) bool {
+	if junkElements[node.DataAtom] || isUnlikely(node) {
+		return true
+	}
+	switch node.DataAtom {
+	case atom.Div, atom.Section, atom.Ul, atom.Ol, atom.Table, atom.Header:
+	case atom.Td: // a cell of a layout table holding navigation links
+		return countElements(node, atom.A) > 2 && linkDensity(node) > 0.5
+	default:
+		return false
+	}
+	weight := classWeight(node)
+	if weight+p.scores[node] < 0 {
+		return true
+	}
+	text := innerText(node)
+	if strings.Count(text, ",") >= 10 {
+		return false
+	}
+	density := linkDensity(node)
+	imgs := countElements(node, atom.Img)
+	switch {
+	case density > 0.2 && weight < 25, density > 0.5:
+		return true
+	case len(text) < 25 && imgs == 0 && node.DataAtom != atom.Header:
+		return countElements(node, atom.Video)+countElements(node, atom.Picture) == 0
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+// innerText returns text of node with whitespace collapsed.
+func innerText(node *html.Node) string {
+	var b strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			for _, word := range strings.FieldsFunc(node.Data, isHTMLSpace) {
+				if b.Len() > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString(word)
+			}
+			return
+		}
+		if node.Type == html.ElementNode && (node.DataAtom == atom.Script || node.DataAtom == atom.Style) {
+			return
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+// linkDensity returns the ratio of link text to all text of node.
+func linkDensity(node *html.Node) float64 {
+	total := len(innerText(node))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	anyForEach(node, func(child *html.Node) error {
+		if child.DataAtom == atom.A && child.Type == html.ElementNode {
+			links += len(innerText(child))
+		}
+		return ErrNotFound
+	})
+	return float64(links) / float64(total)
+}
+
+func countElements(node *html.Node, elem atom.Atom) (n int) {
+	anyForEach(node, func(child *html.Node) error {
+		if child.Type == html.ElementNode && child.DataAtom == elem {
+			n++
+		}
+		return ErrNotFound
+	})
+	return
+}
+
+func findElement(node *html.Node, elem atom.Atom) (ret *html.Node) {
+	anyForEach(node, func(child *html.Node) error {
+		if child.Type == html.ElementNode && child.DataAtom == elem {
+			ret = child
+			return ErrBreak
+		}
+		return ErrNotFound
+	})
+	return
+}
+
+// findMeta returns content of the first <meta> whose property, name or
+// itemprop is one of keys.
+func findMeta(node *html.Node, keys ...string) string {
+	for _, key := range keys {
+		var content string
+		anyForEach(node, func(child *html.Node) error {
+			if child.Type != html.ElementNode || child.DataAtom != atom.Meta {
+				return ErrNotFound
+			}
+			for _, k := range []string{"property", "name", "itemprop"} {
+				if strings.EqualFold(attrOr(child, k, ""), key) {
+					if content = strings.TrimSpace(attrOr(child, "content", "")); content != "" {
+						return ErrBreak
+					}
+				}
+			}
+			return ErrNotFound
+		})
+		if content != "" {
+			return content
+		}
+	}
+	return ""
+}
+
+func articleTitle(node *html.Node) string {
+	if title := findMeta(node, "og:title", "twitter:title"); title != "" {
+		return title
+	}
+	var title string
+	if elem := findElement(node, atom.Title); elem != nil {
+		title = innerText(elem)
+	}
+	// prefer a <h1> which is a part of <title>, eg. "Headline - Site Name"
+	var h1 string
+	anyForEach(node, func(child *html.Node) error {
+		if child.Type == html.ElementNode && child.DataAtom == atom.H1 {
+			if text := innerText(child); text != "" && (title == "" || strings.Contains(title, text)) {
+				h1 = text
+				return ErrBreak
+			}
+		}
+		return ErrNotFound
+	})
+	if h1 != "" {
+		return h1
+	}
+	return title
+}
+
+func articleByline(node *html.Node) string {
+	if byline := findMeta(node, "author", "article:author"); byline != "" && !strings.Contains(byline, "://") {
+		return byline
+	}
+	var byline string
+	anyForEach(node, func(child *html.Node) error {
+		if child.Type != html.ElementNode || junkElements[child.DataAtom] {
+			return ErrNotFound
+		}
+		if attrOr(child, "rel", "") == "author" || attrOr(child, "itemprop", "") == "author" ||
+			bylineHints.MatchString(classID(child)) {
+			if text := innerText(child); text != "" && len(text) < 100 {
+				byline = text
+				return ErrBreak
+			}
+		}
+		return ErrNotFound
+	})
+	return byline
+}
+
+func articleDate(node *html.Node) string {
+	date := findMeta(node, "article:published_time", "datePublished", "pubdate", "publishdate", "date", "dc.date")
+	if date != "" {
+		return date
+	}
+	anyForEach(node, func(child *html.Node) error {
+		if child.Type != html.ElementNode {
+			return ErrNotFound
+		}
+		if child.DataAtom == atom.Time || attrOr(child, "itemprop", "") == "datePublished" {
+			if date = attrOr(child, "datetime", ""); date == "" {
+				date = innerText(child)
+			}
+			if date != "" {
+				return ErrBreak
+			}
+		}
+		return ErrNotFound
+	})
+	return date
+}
+
+// -----------------------------------------------------------------------------
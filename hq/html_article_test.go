@@ -0,0 +1,79 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMainContent runs MainContent on saved pages in testdata/article. Each
+// page NAME.html has a NAME.want file, whose lines are `title: `, `byline: `
+// and `date: ` followed by the expected values, `+ text` for text which must
+// be in the article, and `- text` for text which must not.
+func TestMainContent(t *testing.T) {
+	pages, err := filepath.Glob("testdata/article/*.html")
+	if err != nil || len(pages) == 0 {
+		t.Fatal("no pages:", err)
+	}
+	for _, page := range pages {
+		name := strings.TrimSuffix(page, ".html")
+		want, err := ioutil.ReadFile(name + ".want")
+		if err != nil {
+			t.Fatal(err)
+		}
+		article, err := MainContent(Source.File(page))
+		if err != nil {
+			t.Fatal(page, err)
+		}
+		text := strings.Join(strings.Fields(article.Text()), " ")
+		for _, line := range strings.Split(strings.TrimSpace(string(want)), "\n") {
+			switch {
+			case strings.HasPrefix(line, "+ "):
+				if !strings.Contains(text, line[2:]) {
+					t.Errorf("%s: missing %q", page, line[2:])
+				}
+			case strings.HasPrefix(line, "- "):
+				if strings.Contains(text, line[2:]) {
+					t.Errorf("%s: unexpected %q", page, line[2:])
+				}
+			default:
+				pos := strings.IndexByte(line, ':')
+				if pos < 0 {
+					t.Fatalf("%s: invalid line %q", name+".want", line)
+				}
+				key, val := line[:pos], strings.TrimSpace(line[pos+1:])
+				var got string
+				switch key {
+				case "title":
+					got = article.Title
+				case "byline":
+					got = article.Byline
+				case "date":
+					got = article.Date
+				default:
+					t.Fatalf("%s: unknown key %q", name+".want", key)
+				}
+				if got != val {
+					t.Errorf("%s: %s = %q, want %q", page, key, got, val)
+				}
+			}
+		}
+	}
+}
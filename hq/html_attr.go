@@ -0,0 +1,126 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// -----------------------------------------------------------------------------
+
+// attrMatch returns element nodes who have attribute k (case-insensitive) and
+// whose value satisfies cond. name and args describe the predicate (see match).
+func (p NodeSet) attrMatch(k string, cond func(val string) bool, name string, args ...interface{}) (ret NodeSet) {
+	return p.match(func(node *html.Node) bool {
+		v, err := AttributeVal(node, k)
+		return err == nil && cond(v)
+	}, name, args...)
+}
+
+// HasAttr returns nodes who have attribute k.
+func (p NodeSet) HasAttr(k string) (ret NodeSet) {
+	return p.attrMatch(k, func(val string) bool {
+		return true
+	}, "HasAttr", k)
+}
+
+// AttrPrefix returns nodes whose attribute k's value starts with v.
+func (p NodeSet) AttrPrefix(k, v string) (ret NodeSet) {
+	return p.attrMatch(k, func(val string) bool {
+		return strings.HasPrefix(val, v)
+	}, "AttrPrefix", k, v)
+}
+
+// AttrSuffix returns nodes whose attribute k's value ends with v.
+func (p NodeSet) AttrSuffix(k, v string) (ret NodeSet) {
+	return p.attrMatch(k, func(val string) bool {
+		return strings.HasSuffix(val, v)
+	}, "AttrSuffix", k, v)
+}
+
+// AttrContains returns nodes whose attribute k's value contains v.
+func (p NodeSet) AttrContains(k, v string) (ret NodeSet) {
+	return p.attrMatch(k, func(val string) bool {
+		return strings.Contains(val, v)
+	}, "AttrContains", k, v)
+}
+
+// AttrMatch returns nodes whose attribute k's value matches re.
+func (p NodeSet) AttrMatch(k string, re *regexp.Regexp) (ret NodeSet) {
+	return p.attrMatch(k, re.MatchString, "AttrMatch", k, re)
+}
+
+// HasAllClasses returns nodes whose attribute `class` contains all of classes.
+func (p NodeSet) HasAllClasses(classes ...string) (ret NodeSet) {
+	return p.attrMatch("class", func(val string) bool {
+		have := splitClasses(val)
+		for _, class := range classes {
+			if !containsString(have, class) {
+				return false
+			}
+		}
+		return true
+	}, "HasAllClasses", classes)
+}
+
+// HasAnyClass returns nodes whose attribute `class` contains any of classes.
+func (p NodeSet) HasAnyClass(classes ...string) (ret NodeSet) {
+	return p.attrMatch("class", func(val string) bool {
+		for _, class := range splitClasses(val) {
+			if containsString(classes, class) {
+				return true
+			}
+		}
+		return false
+	}, "HasAnyClass", classes)
+}
+
+// DataAttr returns nodes who have data attribute name. name can be given as
+// `data-user-id`, `user-id` or `userId` (the name in a DOM dataset).
+func (p NodeSet) DataAttr(name string) (ret NodeSet) {
+	return p.HasAttr(dataAttrName(name))
+}
+
+func dataAttrName(name string) string {
+	if strings.HasPrefix(strings.ToLower(name), "data-") {
+		return name
+	}
+	var b strings.Builder
+	b.WriteString("data-")
+	for _, c := range name {
+		if c >= 'A' && c <= 'Z' {
+			b.WriteByte('-')
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,81 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestAttrPredicates(t *testing.T) {
+	doc := Source.String(`<a id="a1" href="https://x.com/a.png" data-user-id="7">1</a><a id="a2" HREF="">2</a>` +
+		`<a id="a3" title="t">3</a><p id="p1" class="x` + "\t" + `y` + "\n" + `z` + "\f" + `w">1</p>` +
+		`<p id="p2" class="xy">2</p><p id="p3" class="">3</p><p id="p4" data-x="1">4</p>`)
+	cases := []struct {
+		name  string
+		chain func(doc NodeSet) NodeSet
+		want  string
+	}{
+		{"HasAttr", func(doc NodeSet) NodeSet { return doc.Any().HasAttr("href") }, "a1 a2"},
+		{"HasAttr upper", func(doc NodeSet) NodeSet { return doc.Any().HasAttr("HREF") }, "a1 a2"},
+		{"HasAttr missing", func(doc NodeSet) NodeSet { return doc.Any().HasAttr("alt") }, ""},
+		{"AttrPrefix", func(doc NodeSet) NodeSet { return doc.Any().AttrPrefix("href", "https:") }, "a1"},
+		{"AttrPrefix empty", func(doc NodeSet) NodeSet { return doc.Any().AttrPrefix("Href", "") }, "a1 a2"},
+		{"AttrSuffix", func(doc NodeSet) NodeSet { return doc.Any().AttrSuffix("Href", ".png") }, "a1"},
+		{"AttrSuffix empty", func(doc NodeSet) NodeSet { return doc.Any().AttrSuffix("title", "") }, "a3"},
+		{"AttrContains", func(doc NodeSet) NodeSet { return doc.Any().AttrContains("href", "x.com") }, "a1"},
+		{"AttrContains empty", func(doc NodeSet) NodeSet { return doc.Any().AttrContains("CLASS", "") }, "p1 p2 p3"},
+		{"AttrMatch", func(doc NodeSet) NodeSet {
+			return doc.Any().AttrMatch("href", regexp.MustCompile(`^https?://`))
+		}, "a1"},
+		{"AttrMatch empty", func(doc NodeSet) NodeSet {
+			return doc.Any().AttrMatch("href", regexp.MustCompile(`^$`))
+		}, "a2"},
+		{"HasAllClasses", func(doc NodeSet) NodeSet { return doc.Any().HasAllClasses("x", "y", "z", "w") }, "p1"},
+		{"HasAllClasses one", func(doc NodeSet) NodeSet { return doc.Any().HasAllClasses("x") }, "p1"},
+		{"HasAllClasses missing", func(doc NodeSet) NodeSet { return doc.Any().HasAllClasses("x", "v") }, ""},
+		{"HasAllClasses none", func(doc NodeSet) NodeSet { return doc.Any().HasAllClasses() }, "p1 p2 p3"},
+		{"HasAnyClass", func(doc NodeSet) NodeSet { return doc.Any().HasAnyClass("w", "xy") }, "p1 p2"},
+		{"HasAnyClass empty", func(doc NodeSet) NodeSet { return doc.Any().HasAnyClass("") }, ""},
+		{"HasAnyClass none", func(doc NodeSet) NodeSet { return doc.Any().HasAnyClass() }, ""},
+		{"DataAttr", func(doc NodeSet) NodeSet { return doc.Any().DataAttr("data-user-id") }, "a1"},
+		{"DataAttr upper", func(doc NodeSet) NodeSet { return doc.Any().DataAttr("DATA-User-Id") }, "a1"},
+		{"DataAttr short", func(doc NodeSet) NodeSet { return doc.Any().DataAttr("user-id") }, "a1"},
+		{"DataAttr dataset", func(doc NodeSet) NodeSet { return doc.Any().DataAttr("userId") }, "a1"},
+		{"DataAttr x", func(doc NodeSet) NodeSet { return doc.Any().DataAttr("x") }, "p4"},
+	}
+	for _, c := range cases {
+		if got := nodeIDs(t, c.chain(doc)); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDataAttrName(t *testing.T) {
+	for name, want := range map[string]string{
+		"data-user-id": "data-user-id",
+		"Data-Id":      "Data-Id",
+		"user-id":      "data-user-id",
+		"userId":       "data-user-id",
+		"userID":       "data-user-i-d",
+		"id":           "data-id",
+	} {
+		if got := dataAttrName(name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
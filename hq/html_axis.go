@@ -0,0 +1,186 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"golang.org/x/net/html"
+)
+
+// -----------------------------------------------------------------------------
+
+// axisNodes visits nodes on an axis of each node. A node reached from more
+// than one node is visited only once.
+type axisNodes struct {
+	data NodeEnum
+	name string
+	axis func(node *html.Node, filter func(node *html.Node) error) error
+}
+
+func (p *axisNodes) ForEach(filter func(node *html.Node) error) {
+	visited := make(map[*html.Node]bool)
+	p.data.ForEach(func(node *html.Node) error {
+		return p.axis(node, func(node *html.Node) error {
+			if visited[node] {
+				return ErrNotFound
+			}
+			visited[node] = true
+			return filter(node)
+		})
+	})
+}
+
+func (p NodeSet) axis(name string, axis func(node *html.Node, filter func(node *html.Node) error) error) (ret NodeSet) {
+	if p.Err != nil {
+		return p
+	}
+	return NodeSet{Data: &axisNodes{p.Data, name, axis}}
+}
+
+// Closest returns the closest node satisfying cond of each node, testing the
+// node itself and then its ancestors.
+func (p NodeSet) Closest(cond func(node *html.Node) bool) (ret NodeSet) {
+	return p.axis("Closest(func)", func(node *html.Node, filter func(node *html.Node) error) error {
+		for ; node != nil; node = node.Parent {
+			if cond(node) {
+				return filter(node)
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// Ancestors returns all ancestors of each node, from parent to the root.
+func (p NodeSet) Ancestors() (ret NodeSet) {
+	return p.axis("Ancestors", func(node *html.Node, filter func(node *html.Node) error) error {
+		for node = node.Parent; node != nil; node = node.Parent {
+			if filter(node) == ErrBreak {
+				return ErrBreak
+			}
+		}
+		return nil
+	})
+}
+
+// Descendants returns all descendants of each node in document order. Unlike
+// Any, it doesn't include the node itself, and visits descendants of matched
+// nodes too.
+func (p NodeSet) Descendants() (ret NodeSet) {
+	return p.axis("Descendants", func(node *html.Node, filter func(node *html.Node) error) error {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if subtreeForEach(child, filter) == ErrBreak {
+				return ErrBreak
+			}
+		}
+		return nil
+	})
+}
+
+// Siblings returns all siblings of each node (excluding itself) in document order.
+func (p NodeSet) Siblings() (ret NodeSet) {
+	return p.axis("Siblings", func(node *html.Node, filter func(node *html.Node) error) error {
+		if node.Parent == nil {
+			return ErrNotFound
+		}
+		for sibling := node.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+			if sibling != node && filter(sibling) == ErrBreak {
+				return ErrBreak
+			}
+		}
+		return nil
+	})
+}
+
+// ChildrenOf returns children of each node whose type is nodeType.
+func (p NodeSet) ChildrenOf(nodeType html.NodeType) (ret NodeSet) {
+	return p.axis("ChildrenOf("+nodeTypeName(nodeType)+")", func(node *html.Node, filter func(node *html.Node) error) error {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == nodeType && filter(child) == ErrBreak {
+				return ErrBreak
+			}
+		}
+		return nil
+	})
+}
+
+// Following returns all nodes after each node in document order, excluding
+// its descendants (as XPath following axis).
+func (p NodeSet) Following() (ret NodeSet) {
+	return p.axis("Following", func(node *html.Node, filter func(node *html.Node) error) error {
+		for ; node != nil; node = node.Parent {
+			for sibling := node.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+				if subtreeForEach(sibling, filter) == ErrBreak {
+					return ErrBreak
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Preceding returns all nodes before each node in document order, excluding
+// its ancestors (as XPath preceding axis). Nodes are visited in document order.
+func (p NodeSet) Preceding() (ret NodeSet) {
+	return p.axis("Preceding", func(node *html.Node, filter func(node *html.Node) error) error {
+		var path []*html.Node
+		for ; node.Parent != nil; node = node.Parent {
+			path = append(path, node)
+		}
+		for i := len(path) - 1; i >= 0; i-- {
+			for sibling := path[i].Parent.FirstChild; sibling != path[i]; sibling = sibling.NextSibling {
+				if subtreeForEach(sibling, filter) == ErrBreak {
+					return ErrBreak
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// subtreeForEach visits node and all its descendants in document order.
+func subtreeForEach(node *html.Node, filter func(node *html.Node) error) error {
+	if filter(node) == ErrBreak {
+		return ErrBreak
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if subtreeForEach(child, filter) == ErrBreak {
+			return ErrBreak
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// Has returns nodes for which subquery returns a non-empty node set, eg.
+//
+//	rows.Has(func(row NodeSet) NodeSet { return row.Any().Th() })
+func (p NodeSet) Has(subquery func(node NodeSet) NodeSet) (ret NodeSet) {
+	return p.match(func(node *html.Node) bool {
+		_, err := subquery(Nodes(node)).collectOne()
+		return err == nil
+	}, "Has(subquery)")
+}
+
+// Not returns nodes for which subquery returns an empty node set.
+func (p NodeSet) Not(subquery func(node NodeSet) NodeSet) (ret NodeSet) {
+	return p.match(func(node *html.Node) bool {
+		_, err := subquery(Nodes(node)).collectOne()
+		return err != nil
+	}, "Not(subquery)")
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,124 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func isTag(tag string) func(node *html.Node) bool {
+	return func(node *html.Node) bool {
+		return node.Type == html.ElementNode && node.Data == tag
+	}
+}
+
+func isElement(node *html.Node) bool {
+	return node.Type == html.ElementNode
+}
+
+func TestAxes(t *testing.T) {
+	doc := Source.String(`<div id="d1"><p id="p1"><b id="b1">1</b></p>` +
+		`<p id="p2"><b id="b2">2</b><i id="i1">i</i></p><p id="p3"></p></div>`)
+	byID := func(id string) NodeSet {
+		return doc.Any().Attribute("id", id)
+	}
+	cases := []struct {
+		name  string
+		chain func() NodeSet
+		want  string // ids of element nodes, or data of other nodes
+	}{
+		{"Closest", func() NodeSet {
+			return Concat(doc.Any().B(), doc.Any().P()).Closest(isTag("p"))
+		}, "p1 p2 p3"},
+		{"Closest self", func() NodeSet {
+			return Concat(byID("b1"), byID("d1")).Closest(isTag("div"))
+		}, "d1"},
+		{"Closest none", func() NodeSet {
+			return doc.Any().B().Closest(isTag("span"))
+		}, ""},
+		{"Ancestors", func() NodeSet {
+			return doc.Any().B().Ancestors().Match(isElement)
+		}, "p1 d1 body html p2"},
+		{"Descendants", func() NodeSet {
+			return Concat(byID("d1"), byID("p2")).Descendants().Match(isElement)
+		}, "p1 b1 p2 b2 i1 p3"},
+		{"Descendants inner first", func() NodeSet {
+			return Concat(byID("p2"), byID("d1")).Descendants().Match(isElement)
+		}, "b2 i1 p1 b1 p2 p3"},
+		{"Descendants text", func() NodeSet {
+			return byID("p2").Descendants()
+		}, "b2 2 i1 i"},
+		{"Siblings", func() NodeSet {
+			return doc.Any().P().Siblings()
+		}, "p2 p3 p1"},
+		{"Siblings detached", func() NodeSet {
+			return Source.Fragment(`<p>a</p>`, "").Siblings()
+		}, ""},
+		{"ChildrenOf", func() NodeSet {
+			return Concat(byID("p2"), byID("p2")).ChildrenOf(html.ElementNode)
+		}, "b2 i1"},
+		{"ChildrenOf text", func() NodeSet {
+			return Concat(byID("b1"), byID("p1"), doc.Any().B()).ChildrenOf(html.TextNode)
+		}, "1 2"},
+		{"Following", func() NodeSet {
+			return byID("b1").Following()
+		}, "p2 b2 2 i1 i p3"},
+		{"Following overlapping", func() NodeSet {
+			return Concat(byID("b2"), byID("b1")).Following().Match(isElement)
+		}, "i1 p3 p2 b2"},
+		{"Preceding", func() NodeSet {
+			return byID("i1").Preceding().Match(isElement)
+		}, "head p1 b1 b2"},
+		{"Preceding overlapping", func() NodeSet {
+			return Concat(byID("i1"), byID("p3")).Preceding().Match(isElement)
+		}, "head p1 b1 b2 p2 i1"},
+		{"Has", func() NodeSet {
+			return doc.Any().P().Has(func(p NodeSet) NodeSet { return p.Child().B() })
+		}, "p1 p2"},
+		{"Has nested", func() NodeSet {
+			return doc.Any().Has(func(node NodeSet) NodeSet { return node.Child().I() }).Match(isElement)
+		}, "p2"},
+		{"Not", func() NodeSet {
+			return doc.Any().P().Not(func(p NodeSet) NodeSet { return p.Child().B() })
+		}, "p3"},
+		{"Not overlapping", func() NodeSet {
+			return Concat(byID("p3"), doc.Any().P()).Not(func(p NodeSet) NodeSet { return p.Child() })
+		}, "p3 p3"},
+	}
+	for _, c := range cases {
+		if got := nodeIDs(t, c.chain()); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestAxisBreak(t *testing.T) {
+	doc := Source.String(largePage(100))
+	n := 0
+	node, err := doc.Any().Ul().Descendants().Match(func(node *html.Node) bool {
+		n++
+		return isTag("a")(node)
+	}).CollectOne()
+	if err != nil || attrOr(node, "href", "") != "/0" {
+		t.Fatal("CollectOne:", node, err)
+	}
+	if n > 10 {
+		t.Fatal("Descendants doesn't stop:", n)
+	}
+}
@@ -0,0 +1,734 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Code generated by gen_elements.go; DO NOT EDIT.
+
+package hq
+
+import (
+	"golang.org/x/net/html/atom"
+)
+
+// -----------------------------------------------------------------------------
+
+// A returns a nodes.
+func (p NodeSet) A() (ret NodeSet) {
+	return p.dataAtom(atom.A)
+}
+
+// Abbr returns abbr nodes.
+func (p NodeSet) Abbr() (ret NodeSet) {
+	return p.dataAtom(atom.Abbr)
+}
+
+// Acronym returns acronym nodes.
+func (p NodeSet) Acronym() (ret NodeSet) {
+	return p.dataAtom(atom.Acronym)
+}
+
+// Address returns address nodes.
+func (p NodeSet) Address() (ret NodeSet) {
+	return p.dataAtom(atom.Address)
+}
+
+// Applet returns applet nodes.
+func (p NodeSet) Applet() (ret NodeSet) {
+	return p.dataAtom(atom.Applet)
+}
+
+// Area returns area nodes.
+func (p NodeSet) Area() (ret NodeSet) {
+	return p.dataAtom(atom.Area)
+}
+
+// Article returns article nodes.
+func (p NodeSet) Article() (ret NodeSet) {
+	return p.dataAtom(atom.Article)
+}
+
+// Aside returns aside nodes.
+func (p NodeSet) Aside() (ret NodeSet) {
+	return p.dataAtom(atom.Aside)
+}
+
+// Audio returns audio nodes.
+func (p NodeSet) Audio() (ret NodeSet) {
+	return p.dataAtom(atom.Audio)
+}
+
+// B returns b nodes.
+func (p NodeSet) B() (ret NodeSet) {
+	return p.dataAtom(atom.B)
+}
+
+// Base returns base nodes.
+func (p NodeSet) Base() (ret NodeSet) {
+	return p.dataAtom(atom.Base)
+}
+
+// Basefont returns basefont nodes.
+func (p NodeSet) Basefont() (ret NodeSet) {
+	return p.dataAtom(atom.Basefont)
+}
+
+// Bdi returns bdi nodes.
+func (p NodeSet) Bdi() (ret NodeSet) {
+	return p.dataAtom(atom.Bdi)
+}
+
+// Bdo returns bdo nodes.
+func (p NodeSet) Bdo() (ret NodeSet) {
+	return p.dataAtom(atom.Bdo)
+}
+
+// Bgsound returns bgsound nodes.
+func (p NodeSet) Bgsound() (ret NodeSet) {
+	return p.dataAtom(atom.Bgsound)
+}
+
+// Big returns big nodes.
+func (p NodeSet) Big() (ret NodeSet) {
+	return p.dataAtom(atom.Big)
+}
+
+// Blink returns blink nodes.
+func (p NodeSet) Blink() (ret NodeSet) {
+	return p.dataAtom(atom.Blink)
+}
+
+// Blockquote returns blockquote nodes.
+func (p NodeSet) Blockquote() (ret NodeSet) {
+	return p.dataAtom(atom.Blockquote)
+}
+
+// Body returns body nodes.
+func (p NodeSet) Body() (ret NodeSet) {
+	return p.dataAtom(atom.Body)
+}
+
+// Br returns br nodes.
+func (p NodeSet) Br() (ret NodeSet) {
+	return p.dataAtom(atom.Br)
+}
+
+// Button returns button nodes.
+func (p NodeSet) Button() (ret NodeSet) {
+	return p.dataAtom(atom.Button)
+}
+
+// Canvas returns canvas nodes.
+func (p NodeSet) Canvas() (ret NodeSet) {
+	return p.dataAtom(atom.Canvas)
+}
+
+// Caption returns caption nodes.
+func (p NodeSet) Caption() (ret NodeSet) {
+	return p.dataAtom(atom.Caption)
+}
+
+// Center returns center nodes.
+func (p NodeSet) Center() (ret NodeSet) {
+	return p.dataAtom(atom.Center)
+}
+
+// Cite returns cite nodes.
+func (p NodeSet) Cite() (ret NodeSet) {
+	return p.dataAtom(atom.Cite)
+}
+
+// Code returns code nodes.
+func (p NodeSet) Code() (ret NodeSet) {
+	return p.dataAtom(atom.Code)
+}
+
+// Col returns col nodes.
+func (p NodeSet) Col() (ret NodeSet) {
+	return p.dataAtom(atom.Col)
+}
+
+// Colgroup returns colgroup nodes.
+func (p NodeSet) Colgroup() (ret NodeSet) {
+	return p.dataAtom(atom.Colgroup)
+}
+
+// Command returns command nodes.
+func (p NodeSet) Command() (ret NodeSet) {
+	return p.dataAtom(atom.Command)
+}
+
+// DataElement returns data nodes.
+// It isn't named Data, which is the NodeEnum field of NodeSet.
+func (p NodeSet) DataElement() (ret NodeSet) {
+	return p.dataAtom(atom.Data)
+}
+
+// Datalist returns datalist nodes.
+func (p NodeSet) Datalist() (ret NodeSet) {
+	return p.dataAtom(atom.Datalist)
+}
+
+// Dd returns dd nodes.
+func (p NodeSet) Dd() (ret NodeSet) {
+	return p.dataAtom(atom.Dd)
+}
+
+// Del returns del nodes.
+func (p NodeSet) Del() (ret NodeSet) {
+	return p.dataAtom(atom.Del)
+}
+
+// Details returns details nodes.
+func (p NodeSet) Details() (ret NodeSet) {
+	return p.dataAtom(atom.Details)
+}
+
+// Dfn returns dfn nodes.
+func (p NodeSet) Dfn() (ret NodeSet) {
+	return p.dataAtom(atom.Dfn)
+}
+
+// Dialog returns dialog nodes.
+func (p NodeSet) Dialog() (ret NodeSet) {
+	return p.dataAtom(atom.Dialog)
+}
+
+// Div returns div nodes.
+func (p NodeSet) Div() (ret NodeSet) {
+	return p.dataAtom(atom.Div)
+}
+
+// Dl returns dl nodes.
+func (p NodeSet) Dl() (ret NodeSet) {
+	return p.dataAtom(atom.Dl)
+}
+
+// Dt returns dt nodes.
+func (p NodeSet) Dt() (ret NodeSet) {
+	return p.dataAtom(atom.Dt)
+}
+
+// Em returns em nodes.
+func (p NodeSet) Em() (ret NodeSet) {
+	return p.dataAtom(atom.Em)
+}
+
+// Embed returns embed nodes.
+func (p NodeSet) Embed() (ret NodeSet) {
+	return p.dataAtom(atom.Embed)
+}
+
+// Fieldset returns fieldset nodes.
+func (p NodeSet) Fieldset() (ret NodeSet) {
+	return p.dataAtom(atom.Fieldset)
+}
+
+// Figcaption returns figcaption nodes.
+func (p NodeSet) Figcaption() (ret NodeSet) {
+	return p.dataAtom(atom.Figcaption)
+}
+
+// Figure returns figure nodes.
+func (p NodeSet) Figure() (ret NodeSet) {
+	return p.dataAtom(atom.Figure)
+}
+
+// Font returns font nodes.
+func (p NodeSet) Font() (ret NodeSet) {
+	return p.dataAtom(atom.Font)
+}
+
+// Footer returns footer nodes.
+func (p NodeSet) Footer() (ret NodeSet) {
+	return p.dataAtom(atom.Footer)
+}
+
+// FormElement returns form nodes.
+// It isn't named Form, which is the method returning the first form model.
+func (p NodeSet) FormElement() (ret NodeSet) {
+	return p.dataAtom(atom.Form)
+}
+
+// Frame returns frame nodes.
+func (p NodeSet) Frame() (ret NodeSet) {
+	return p.dataAtom(atom.Frame)
+}
+
+// Frameset returns frameset nodes.
+func (p NodeSet) Frameset() (ret NodeSet) {
+	return p.dataAtom(atom.Frameset)
+}
+
+// H1 returns h1 nodes.
+func (p NodeSet) H1() (ret NodeSet) {
+	return p.dataAtom(atom.H1)
+}
+
+// H2 returns h2 nodes.
+func (p NodeSet) H2() (ret NodeSet) {
+	return p.dataAtom(atom.H2)
+}
+
+// H3 returns h3 nodes.
+func (p NodeSet) H3() (ret NodeSet) {
+	return p.dataAtom(atom.H3)
+}
+
+// H4 returns h4 nodes.
+func (p NodeSet) H4() (ret NodeSet) {
+	return p.dataAtom(atom.H4)
+}
+
+// H5 returns h5 nodes.
+func (p NodeSet) H5() (ret NodeSet) {
+	return p.dataAtom(atom.H5)
+}
+
+// H6 returns h6 nodes.
+func (p NodeSet) H6() (ret NodeSet) {
+	return p.dataAtom(atom.H6)
+}
+
+// Head returns head nodes.
+func (p NodeSet) Head() (ret NodeSet) {
+	return p.dataAtom(atom.Head)
+}
+
+// Header returns header nodes.
+func (p NodeSet) Header() (ret NodeSet) {
+	return p.dataAtom(atom.Header)
+}
+
+// Hgroup returns hgroup nodes.
+func (p NodeSet) Hgroup() (ret NodeSet) {
+	return p.dataAtom(atom.Hgroup)
+}
+
+// Hr returns hr nodes.
+func (p NodeSet) Hr() (ret NodeSet) {
+	return p.dataAtom(atom.Hr)
+}
+
+// Html returns html nodes.
+func (p NodeSet) Html() (ret NodeSet) {
+	return p.dataAtom(atom.Html)
+}
+
+// I returns i nodes.
+func (p NodeSet) I() (ret NodeSet) {
+	return p.dataAtom(atom.I)
+}
+
+// Iframe returns iframe nodes.
+func (p NodeSet) Iframe() (ret NodeSet) {
+	return p.dataAtom(atom.Iframe)
+}
+
+// Image returns image nodes.
+func (p NodeSet) Image() (ret NodeSet) {
+	return p.dataAtom(atom.Image)
+}
+
+// Img returns img nodes.
+func (p NodeSet) Img() (ret NodeSet) {
+	return p.dataAtom(atom.Img)
+}
+
+// Input returns input nodes.
+func (p NodeSet) Input() (ret NodeSet) {
+	return p.dataAtom(atom.Input)
+}
+
+// Ins returns ins nodes.
+func (p NodeSet) Ins() (ret NodeSet) {
+	return p.dataAtom(atom.Ins)
+}
+
+// Isindex returns isindex nodes.
+func (p NodeSet) Isindex() (ret NodeSet) {
+	return p.dataAtom(atom.Isindex)
+}
+
+// Kbd returns kbd nodes.
+func (p NodeSet) Kbd() (ret NodeSet) {
+	return p.dataAtom(atom.Kbd)
+}
+
+// Keygen returns keygen nodes.
+func (p NodeSet) Keygen() (ret NodeSet) {
+	return p.dataAtom(atom.Keygen)
+}
+
+// Label returns label nodes.
+func (p NodeSet) Label() (ret NodeSet) {
+	return p.dataAtom(atom.Label)
+}
+
+// Legend returns legend nodes.
+func (p NodeSet) Legend() (ret NodeSet) {
+	return p.dataAtom(atom.Legend)
+}
+
+// Li returns li nodes.
+func (p NodeSet) Li() (ret NodeSet) {
+	return p.dataAtom(atom.Li)
+}
+
+// Link returns link nodes.
+func (p NodeSet) Link() (ret NodeSet) {
+	return p.dataAtom(atom.Link)
+}
+
+// Listing returns listing nodes.
+func (p NodeSet) Listing() (ret NodeSet) {
+	return p.dataAtom(atom.Listing)
+}
+
+// Main returns main nodes.
+func (p NodeSet) Main() (ret NodeSet) {
+	return p.dataAtom(atom.Main)
+}
+
+// Map returns map nodes.
+func (p NodeSet) Map() (ret NodeSet) {
+	return p.dataAtom(atom.Map)
+}
+
+// Mark returns mark nodes.
+func (p NodeSet) Mark() (ret NodeSet) {
+	return p.dataAtom(atom.Mark)
+}
+
+// Marquee returns marquee nodes.
+func (p NodeSet) Marquee() (ret NodeSet) {
+	return p.dataAtom(atom.Marquee)
+}
+
+// Math returns math nodes.
+func (p NodeSet) Math() (ret NodeSet) {
+	return p.dataAtom(atom.Math)
+}
+
+// Menu returns menu nodes.
+func (p NodeSet) Menu() (ret NodeSet) {
+	return p.dataAtom(atom.Menu)
+}
+
+// Menuitem returns menuitem nodes.
+func (p NodeSet) Menuitem() (ret NodeSet) {
+	return p.dataAtom(atom.Menuitem)
+}
+
+// Meta returns meta nodes.
+func (p NodeSet) Meta() (ret NodeSet) {
+	return p.dataAtom(atom.Meta)
+}
+
+// Meter returns meter nodes.
+func (p NodeSet) Meter() (ret NodeSet) {
+	return p.dataAtom(atom.Meter)
+}
+
+// Nav returns nav nodes.
+func (p NodeSet) Nav() (ret NodeSet) {
+	return p.dataAtom(atom.Nav)
+}
+
+// Nobr returns nobr nodes.
+func (p NodeSet) Nobr() (ret NodeSet) {
+	return p.dataAtom(atom.Nobr)
+}
+
+// Noembed returns noembed nodes.
+func (p NodeSet) Noembed() (ret NodeSet) {
+	return p.dataAtom(atom.Noembed)
+}
+
+// Noframes returns noframes nodes.
+func (p NodeSet) Noframes() (ret NodeSet) {
+	return p.dataAtom(atom.Noframes)
+}
+
+// Noscript returns noscript nodes.
+func (p NodeSet) Noscript() (ret NodeSet) {
+	return p.dataAtom(atom.Noscript)
+}
+
+// Object returns object nodes.
+func (p NodeSet) Object() (ret NodeSet) {
+	return p.dataAtom(atom.Object)
+}
+
+// Ol returns ol nodes.
+func (p NodeSet) Ol() (ret NodeSet) {
+	return p.dataAtom(atom.Ol)
+}
+
+// Optgroup returns optgroup nodes.
+func (p NodeSet) Optgroup() (ret NodeSet) {
+	return p.dataAtom(atom.Optgroup)
+}
+
+// Option returns option nodes.
+func (p NodeSet) Option() (ret NodeSet) {
+	return p.dataAtom(atom.Option)
+}
+
+// Output returns output nodes.
+func (p NodeSet) Output() (ret NodeSet) {
+	return p.dataAtom(atom.Output)
+}
+
+// P returns p nodes.
+func (p NodeSet) P() (ret NodeSet) {
+	return p.dataAtom(atom.P)
+}
+
+// Param returns param nodes.
+func (p NodeSet) Param() (ret NodeSet) {
+	return p.dataAtom(atom.Param)
+}
+
+// Picture returns picture nodes.
+func (p NodeSet) Picture() (ret NodeSet) {
+	return p.dataAtom(atom.Picture)
+}
+
+// Plaintext returns plaintext nodes.
+func (p NodeSet) Plaintext() (ret NodeSet) {
+	return p.dataAtom(atom.Plaintext)
+}
+
+// Pre returns pre nodes.
+func (p NodeSet) Pre() (ret NodeSet) {
+	return p.dataAtom(atom.Pre)
+}
+
+// Progress returns progress nodes.
+func (p NodeSet) Progress() (ret NodeSet) {
+	return p.dataAtom(atom.Progress)
+}
+
+// Q returns q nodes.
+func (p NodeSet) Q() (ret NodeSet) {
+	return p.dataAtom(atom.Q)
+}
+
+// Rb returns rb nodes.
+func (p NodeSet) Rb() (ret NodeSet) {
+	return p.dataAtom(atom.Rb)
+}
+
+// Rp returns rp nodes.
+func (p NodeSet) Rp() (ret NodeSet) {
+	return p.dataAtom(atom.Rp)
+}
+
+// Rt returns rt nodes.
+func (p NodeSet) Rt() (ret NodeSet) {
+	return p.dataAtom(atom.Rt)
+}
+
+// Rtc returns rtc nodes.
+func (p NodeSet) Rtc() (ret NodeSet) {
+	return p.dataAtom(atom.Rtc)
+}
+
+// Ruby returns ruby nodes.
+func (p NodeSet) Ruby() (ret NodeSet) {
+	return p.dataAtom(atom.Ruby)
+}
+
+// S returns s nodes.
+func (p NodeSet) S() (ret NodeSet) {
+	return p.dataAtom(atom.S)
+}
+
+// Samp returns samp nodes.
+func (p NodeSet) Samp() (ret NodeSet) {
+	return p.dataAtom(atom.Samp)
+}
+
+// Script returns script nodes.
+func (p NodeSet) Script() (ret NodeSet) {
+	return p.dataAtom(atom.Script)
+}
+
+// Section returns section nodes.
+func (p NodeSet) Section() (ret NodeSet) {
+	return p.dataAtom(atom.Section)
+}
+
+// Select returns select nodes.
+func (p NodeSet) Select() (ret NodeSet) {
+	return p.dataAtom(atom.Select)
+}
+
+// Slot returns slot nodes.
+func (p NodeSet) Slot() (ret NodeSet) {
+	return p.dataAtom(atom.Slot)
+}
+
+// Small returns small nodes.
+func (p NodeSet) Small() (ret NodeSet) {
+	return p.dataAtom(atom.Small)
+}
+
+// Source returns source nodes.
+func (p NodeSet) Source() (ret NodeSet) {
+	return p.dataAtom(atom.Source)
+}
+
+// Spacer returns spacer nodes.
+func (p NodeSet) Spacer() (ret NodeSet) {
+	return p.dataAtom(atom.Spacer)
+}
+
+// Span returns span nodes.
+func (p NodeSet) Span() (ret NodeSet) {
+	return p.dataAtom(atom.Span)
+}
+
+// Strike returns strike nodes.
+func (p NodeSet) Strike() (ret NodeSet) {
+	return p.dataAtom(atom.Strike)
+}
+
+// Strong returns strong nodes.
+func (p NodeSet) Strong() (ret NodeSet) {
+	return p.dataAtom(atom.Strong)
+}
+
+// Style returns style nodes.
+func (p NodeSet) Style() (ret NodeSet) {
+	return p.dataAtom(atom.Style)
+}
+
+// Sub returns sub nodes.
+func (p NodeSet) Sub() (ret NodeSet) {
+	return p.dataAtom(atom.Sub)
+}
+
+// Summary returns summary nodes.
+func (p NodeSet) Summary() (ret NodeSet) {
+	return p.dataAtom(atom.Summary)
+}
+
+// Sup returns sup nodes.
+func (p NodeSet) Sup() (ret NodeSet) {
+	return p.dataAtom(atom.Sup)
+}
+
+// Svg returns svg nodes.
+func (p NodeSet) Svg() (ret NodeSet) {
+	return p.dataAtom(atom.Svg)
+}
+
+// Table returns table nodes.
+func (p NodeSet) Table() (ret NodeSet) {
+	return p.dataAtom(atom.Table)
+}
+
+// Tbody returns tbody nodes.
+func (p NodeSet) Tbody() (ret NodeSet) {
+	return p.dataAtom(atom.Tbody)
+}
+
+// Td returns td nodes.
+func (p NodeSet) Td() (ret NodeSet) {
+	return p.dataAtom(atom.Td)
+}
+
+// Template returns template nodes.
+func (p NodeSet) Template() (ret NodeSet) {
+	return p.dataAtom(atom.Template)
+}
+
+// Textarea returns textarea nodes.
+func (p NodeSet) Textarea() (ret NodeSet) {
+	return p.dataAtom(atom.Textarea)
+}
+
+// Tfoot returns tfoot nodes.
+func (p NodeSet) Tfoot() (ret NodeSet) {
+	return p.dataAtom(atom.Tfoot)
+}
+
+// Th returns th nodes.
+func (p NodeSet) Th() (ret NodeSet) {
+	return p.dataAtom(atom.Th)
+}
+
+// Thead returns thead nodes.
+func (p NodeSet) Thead() (ret NodeSet) {
+	return p.dataAtom(atom.Thead)
+}
+
+// Time returns time nodes.
+func (p NodeSet) Time() (ret NodeSet) {
+	return p.dataAtom(atom.Time)
+}
+
+// Title returns title nodes.
+func (p NodeSet) Title() (ret NodeSet) {
+	return p.dataAtom(atom.Title)
+}
+
+// Tr returns tr nodes.
+func (p NodeSet) Tr() (ret NodeSet) {
+	return p.dataAtom(atom.Tr)
+}
+
+// Track returns track nodes.
+func (p NodeSet) Track() (ret NodeSet) {
+	return p.dataAtom(atom.Track)
+}
+
+// Tt returns tt nodes.
+func (p NodeSet) Tt() (ret NodeSet) {
+	return p.dataAtom(atom.Tt)
+}
+
+// U returns u nodes.
+func (p NodeSet) U() (ret NodeSet) {
+	return p.dataAtom(atom.U)
+}
+
+// Ul returns ul nodes.
+func (p NodeSet) Ul() (ret NodeSet) {
+	return p.dataAtom(atom.Ul)
+}
+
+// Var returns var nodes.
+func (p NodeSet) Var() (ret NodeSet) {
+	return p.dataAtom(atom.Var)
+}
+
+// Video returns video nodes.
+func (p NodeSet) Video() (ret NodeSet) {
+	return p.dataAtom(atom.Video)
+}
+
+// Wbr returns wbr nodes.
+func (p NodeSet) Wbr() (ret NodeSet) {
+	return p.dataAtom(atom.Wbr)
+}
+
+// Xmp returns xmp nodes.
+func (p NodeSet) Xmp() (ret NodeSet) {
+	return p.dataAtom(atom.Xmp)
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,85 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// TestElementShortcuts checks that each shortcut in html_elements.go matches
+// elements of the atom it names, and nothing else.
+func TestElementShortcuts(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "html_elements.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortcuts := make(map[string]atom.Atom) // method name -> atom
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil {
+			continue
+		}
+		var name string
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == "atom" {
+					name = sel.Sel.Name
+				}
+			}
+			return true
+		})
+		a := atom.Lookup([]byte(strings.ToLower(name)))
+		if a == 0 {
+			t.Fatalf("%s: unknown atom %q", fn.Name.Name, name)
+		}
+		shortcuts[fn.Name.Name] = a
+	}
+	if len(shortcuts) < 100 {
+		t.Fatal("too few shortcuts:", len(shortcuts))
+	}
+
+	root := &html.Node{Type: html.ElementNode, DataAtom: atom.Div, Data: "div"}
+	for _, a := range shortcuts {
+		root.AppendChild(&html.Node{Type: html.ElementNode, DataAtom: a, Data: a.String()})
+	}
+	children := Nodes(root).Child()
+	for method, a := range shortcuts {
+		m := reflect.ValueOf(children).MethodByName(method)
+		if !m.IsValid() {
+			t.Fatalf("%s isn't a method of NodeSet", method)
+		}
+		ns := m.Call(nil)[0].Interface().(NodeSet)
+		nodes, err := ns.Collect()
+		if err != nil || len(nodes) != 1 || nodes[0].DataAtom != a {
+			t.Errorf("%s: got %v, %v, want a %s", method, nodes, err, a)
+		}
+	}
+	if _, ok := shortcuts["FormElement"]; !ok {
+		t.Fatal("FormElement is missing")
+	}
+	if _, ok := shortcuts["DataElement"]; !ok {
+		t.Fatal("DataElement is missing")
+	}
+}
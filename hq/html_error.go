@@ -0,0 +1,178 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// -----------------------------------------------------------------------------
+
+// QueryError - an error of a query, which tells where the query fails, eg.
+//
+//	no such file or directory: Document > Any(all) where Element("table") > [Child] (last matched /html/body/table, a.html)
+//
+// CollectOne, getters (Text, AttrVal, etc.), mutations (Remove, SetAttr, etc.)
+// and Freeze return errors of the query as a *QueryError. It wraps the
+// underlying error, so `errors.Is(err, hq.ErrNotFound)` works.
+type QueryError struct {
+	Err    error    // the underlying error, eg. ErrNotFound
+	Steps  []string // steps of the query from the source (see NodeSet.Explain)
+	Step   int      // index of the failed step in Steps, -1 if unknown
+	Source string   // url or file name of the document, "" if unknown
+	Path   string   // path of the last matched node (see NodePath), "" if unknown
+}
+
+func (p *QueryError) Error() string {
+	var b strings.Builder
+	b.WriteString(p.Err.Error())
+	for i, step := range p.Steps {
+		switch {
+		case i == 0:
+			b.WriteString(": ")
+		default:
+			b.WriteString(" > ")
+		}
+		if i == p.Step {
+			b.WriteString("[" + step + "]")
+		} else {
+			b.WriteString(step)
+		}
+	}
+	var where []string
+	if p.Path != "" {
+		where = append(where, "last matched "+p.Path)
+	}
+	if p.Source != "" {
+		where = append(where, p.Source)
+	}
+	if where != nil {
+		b.WriteString(" (" + strings.Join(where, ", ") + ")")
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (p *QueryError) Unwrap() error {
+	return p.Err
+}
+
+// stepProbe records the first node which a step of a chain yields.
+type stepProbe struct {
+	data  NodeEnum
+	first *html.Node
+}
+
+func (p *stepProbe) ForEach(filter func(node *html.Node) error) {
+	p.data.ForEach(func(node *html.Node) error {
+		if p.first == nil {
+			p.first = node
+		}
+		return filter(node)
+	})
+}
+
+// queryRun is a visit of a chain with a stepProbe after each step, so that it
+// can tell which step fails without running the chain again (which would call
+// Match functions and subqueries again).
+type queryRun struct {
+	steps  []string
+	probes []*stepProbe
+	data   NodeEnum   // the chain with probes
+	src    *sourceRun // the source if it's a failingNodeEnum, see forEachErr
+}
+
+func newQueryRun(data NodeEnum) *queryRun {
+	chain, steps := chainOf(data)
+	run := &queryRun{steps: steps, probes: make([]*stepProbe, len(chain))}
+	for i, step := range chain {
+		if i > 0 {
+			step = step.(planNode).withInput(data)
+		} else if src, ok := step.(failingNodeEnum); ok {
+			run.src = &sourceRun{src: src}
+			step = run.src
+		}
+		switch step.(type) {
+		case oneNode, *indexedDoc:
+			// not probed, since the next step may look into the source, eg.
+			// anyMatchedNodes looks up the index
+			run.probes[i] = &stepProbe{data: step, first: firstNode(step)}
+			data = step
+		default:
+			run.probes[i] = &stepProbe{data: step}
+			data = run.probes[i]
+		}
+	}
+	run.data = data
+	return run
+}
+
+// forEach visits the chain, and returns the error of its source.
+func (p *queryRun) forEach(filter func(node *html.Node) error) error {
+	p.data.ForEach(filter)
+	if p.src != nil {
+		return p.src.err
+	}
+	return nil
+}
+
+// error returns a *QueryError of err (ErrNotFound or ErrTooManyNodes) which
+// the run fails to collect one node with. For ErrNotFound the failed step is
+// the first step having no nodes, and Path is the first node of the step
+// before it; for ErrTooManyNodes it's the last step, and Path is its second
+// node.
+func (p *queryRun) error(err error, second *html.Node) error {
+	e := &QueryError{Err: err, Steps: p.steps, Step: -1}
+	if node := p.probes[0].first; node != nil {
+		e.Source = sourceName(node)
+	}
+	if err == ErrTooManyNodes {
+		e.Step, e.Path = len(p.steps)-1, NodePath(second)
+		return e
+	}
+	for i, probe := range p.probes {
+		if probe.first == nil {
+			e.Step = i
+			break
+		}
+		e.Path = NodePath(probe.first)
+	}
+	return e
+}
+
+func firstNode(data NodeEnum) (ret *html.Node) {
+	data.ForEach(func(node *html.Node) error {
+		ret = node
+		return ErrBreak
+	})
+	return
+}
+
+// nodeError returns a *QueryError of err which operation op fails with on
+// node, the node collected from the node set.
+func (p NodeSet) nodeError(node *html.Node, op string, err error) error {
+	_, steps := chainOf(p.Data)
+	steps = append(steps, op)
+	return &QueryError{
+		Err: err, Steps: steps, Step: len(steps) - 1,
+		Source: sourceName(node), Path: NodePath(node),
+	}
+}
+
+// -----------------------------------------------------------------------------
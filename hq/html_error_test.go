@@ -0,0 +1,114 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestQueryError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.html")
+	err := ioutil.WriteFile(file, []byte(`<div class="a"><p>x</p><p>y</p></div>`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := Source.File(file)
+	cases := []struct {
+		name string
+		err  func() error
+		want error
+		step int
+		path string
+		msg  string
+	}{
+		{"not found", func() error {
+			_, err := doc.Any().Div().ContainsClass("a").Child().Span().CollectOne()
+			return err
+		}, ErrNotFound, 3, "/html/body/div/p[1]",
+			`Document > Any(all) where Element("div") and ContainsClass("a") > Child > [Match(Element("span"))]`},
+		{"first step", func() error {
+			_, err := doc.Any().Span().Child().Text()
+			return err
+		}, ErrNotFound, 1, "/",
+			`Document > [Any(all) where Element("span")] > Child`},
+		{"too many", func() error {
+			_, err := doc.Any().P().CollectOne(true)
+			return err
+		}, ErrTooManyNodes, 1, "/html/body/div/p[2]",
+			`Document > [Any(all) where Element("p")]`},
+		{"getter", func() error {
+			_, err := doc.Any().Div().AttrVal("href")
+			return err
+		}, ErrNotFound, 2, "/html/body/div",
+			`Document > Any(all) where Element("div") > [AttrVal("href")]`},
+		{"mutation", func() error {
+			return doc.Any().P().Freeze().SetAttr("id", "x").Err
+		}, ErrFrozen, 2, "/html/body/div/p[1]",
+			`Document > Any(all) where Element("p") > [SetAttr("id")]`},
+		{"ChildrenAsText", func() error { // the document is frozen by the previous case
+			return doc.Any().P().ChildrenAsText(true).Err
+		}, ErrFrozen, 2, "/html/body/div/p[1]",
+			`Document > Any(all) where Element("p") > [ChildrenAsText(true)]`},
+	}
+	for _, c := range cases {
+		err := c.err()
+		var qe *QueryError
+		if !errors.As(err, &qe) || !errors.Is(err, c.want) {
+			t.Fatalf("%s: %#v", c.name, err)
+		}
+		if qe.Step != c.step || qe.Path != c.path || qe.Source != file {
+			t.Errorf("%s: step %d, path %q, source %q", c.name, qe.Step, qe.Path, qe.Source)
+		}
+		msg := c.want.Error() + ": " + c.msg + " (last matched " + c.path + ", " + file + ")"
+		if err.Error() != msg {
+			t.Errorf("%s:\ngot  %s\nwant %s", c.name, err.Error(), msg)
+		}
+	}
+
+	e := &QueryError{Err: ErrNotFound, Steps: []string{"Input", "Child"}, Step: -1}
+	if msg := e.Error(); msg != "no such file or directory: Input > Child" {
+		t.Error("unknown step:", msg)
+	}
+}
+
+func TestQueryErrorNoRerun(t *testing.T) {
+	doc := Source.String(`<div><p>x</p><p>y</p></div>`)
+	calls, subqueries := 0, 0
+	_, err := doc.Any().Match(func(node *html.Node) bool {
+		calls++
+		return true
+	}).Has(func(ns NodeSet) NodeSet {
+		subqueries++
+		return ns.Span()
+	}).CollectOne()
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal(err)
+	}
+	var nodes int
+	subtreeForEach(Root(firstNode(doc.Data)), func(node *html.Node) error {
+		nodes++
+		return nil
+	})
+	if calls != nodes || subqueries != nodes {
+		t.Fatalf("%d nodes, %d calls of Match, %d subqueries", nodes, calls, subqueries)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// FieldError - an error of extracting a field.
+type FieldError struct {
+	Field string // name of the field, "" if unnamed
+	Path  string // path of the last matched node (see QueryError), "" if unknown
+	Err   error
+}
+
+func (p *FieldError) Error() string {
+	if p.Field == "" {
+		return p.Err.Error()
+	}
+	return "field " + strconv.Quote(p.Field) + ": " + p.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (p *FieldError) Unwrap() error {
+	return p.Err
+}
+
+// ExtractErrors - errors of an Extractor, in the order they occur.
+type ExtractErrors []*FieldError
+
+func (p ExtractErrors) Error() string {
+	msgs := make([]string, len(p))
+	for i, err := range p {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// -----------------------------------------------------------------------------
+
+// ExtractOptions - options of an Extractor.
+type ExtractOptions struct {
+	// Optional makes fields optional unless they are got by Extractor.Required.
+	Optional bool
+
+	// ExactlyOne makes a field fail with ErrTooManyNodes if its node set has
+	// more than one node, instead of taking the first one.
+	ExactlyOne bool
+}
+
+// Extractor - an extraction context, which extracts fields without per-call
+// error handling and collects their errors, eg.
+//
+//	x := hq.NewExtractor(nil)
+//	title := x.Field("title").Text(item.Any().H2())
+//	price := x.Optional("price").Int(item.Any().Span().Class("price"))
+//	link := x.Attr(item.Any().A(), "href")
+//	if err := x.Err(); err != nil {
+//		...
+//	}
+//
+// A failed field gets the zero value. A required field records any error,
+// while an optional field ignores ErrNotFound (its node or attribute doesn't
+// exist) and records other errors (eg. Int of "abc").
+//
+// An Extractor isn't safe for concurrent use, use one for each item instead.
+// The zero value is an Extractor with default options.
+type Extractor struct {
+	opts ExtractOptions
+	errs ExtractErrors
+}
+
+// NewExtractor creates an Extractor. opts can be nil for default options.
+func NewExtractor(opts *ExtractOptions) *Extractor {
+	p := new(Extractor)
+	if opts != nil {
+		p.opts = *opts
+	}
+	return p
+}
+
+// Err returns all errors as ExtractErrors, or nil if no field fails.
+func (p *Extractor) Err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return p.errs
+}
+
+// Field returns the field named name, optional if ExtractOptions.Optional is true.
+func (p *Extractor) Field(name string) Field {
+	return Field{p, name, p.opts.Optional}
+}
+
+// Required returns the required field named name.
+func (p *Extractor) Required(name string) Field {
+	return Field{p, name, false}
+}
+
+// Optional returns the optional field named name.
+func (p *Extractor) Optional(name string) Field {
+	return Field{p, name, true}
+}
+
+// Text returns text of an unnamed field, see NodeSet.Text.
+func (p *Extractor) Text(ns NodeSet) string {
+	return p.Field("").Text(ns)
+}
+
+// Int returns an integer of an unnamed field, see NodeSet.Int.
+func (p *Extractor) Int(ns NodeSet) int {
+	return p.Field("").Int(ns)
+}
+
+// Float returns a united float of an unnamed field, see NodeSet.UnitedFloat.
+func (p *Extractor) Float(ns NodeSet) float64 {
+	return p.Field("").Float(ns)
+}
+
+// Attr returns attribute k's value of an unnamed field, see NodeSet.AttrVal.
+func (p *Extractor) Attr(ns NodeSet, k string) string {
+	return p.Field("").Attr(ns, k)
+}
+
+// -----------------------------------------------------------------------------
+
+// Field - a field of an Extractor.
+type Field struct {
+	x        *Extractor
+	name     string
+	optional bool
+}
+
+// Text returns node's text, see NodeSet.Text.
+func (p Field) Text(ns NodeSet) string {
+	text, err := ns.Text(p.exactlyOne()...)
+	p.check(err)
+	return text
+}
+
+// Int gets node's text and converts it into an integer, see NodeSet.Int.
+func (p Field) Int(ns NodeSet) int {
+	v, err := ns.Int(p.exactlyOne()...)
+	if p.check(err) {
+		return 0
+	}
+	return v
+}
+
+// Float gets node's text and converts it into a united float, see
+// NodeSet.UnitedFloat.
+func (p Field) Float(ns NodeSet) float64 {
+	v, err := ns.UnitedFloat(p.exactlyOne()...)
+	if p.check(err) {
+		return 0
+	}
+	return v
+}
+
+// Attr returns node attribute k's value, see NodeSet.AttrVal.
+func (p Field) Attr(ns NodeSet, k string) string {
+	v, err := ns.AttrVal(k, p.exactlyOne()...)
+	p.check(err)
+	return v
+}
+
+func (p Field) exactlyOne() []bool {
+	if p.x.opts.ExactlyOne {
+		return []bool{true}
+	}
+	return nil
+}
+
+// check records err unless it's nil or ignored by the field, and returns if
+// the field fails.
+func (p Field) check(err error) bool {
+	if err == nil {
+		return false
+	}
+	var qe *QueryError
+	isQuery := errors.As(err, &qe)
+	if p.optional && isQuery && errors.Is(qe.Err, ErrNotFound) { // not eg. a missing file of the source
+		return true
+	}
+	fe := &FieldError{Field: p.name, Err: err}
+	if isQuery {
+		fe.Path = qe.Path
+	}
+	p.x.errs = append(p.x.errs, fe)
+	return true
+}
+
+// -----------------------------------------------------------------------------
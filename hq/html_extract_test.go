@@ -0,0 +1,120 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const extractPage = `<div class="item"><h2>Title</h2><span class="price">12</span>
+<span class="price">13</span><span class="bad">abc</span><a href="/x">x</a></div>`
+
+func TestExtractorPolicy(t *testing.T) {
+	item := Source.String(extractPage).Any().Div().Class("item")
+	cases := []struct {
+		name   string
+		opts   *ExtractOptions
+		fields []string // names of failed fields
+	}{
+		{"required", nil, []string{"missing", "missing attr", "bad", "missing source"}},
+		{"optional", &ExtractOptions{Optional: true}, []string{"bad", "missing source", "required"}},
+	}
+	for _, c := range cases {
+		x := NewExtractor(c.opts)
+		if title := x.Field("title").Text(item.Any().H2()); title != "Title" {
+			t.Fatal(c.name, "title:", title)
+		}
+		if price := x.Field("price").Int(item.Any().Span().Class("price")); price != 12 {
+			t.Fatal(c.name, "price:", price)
+		}
+		if v := x.Field("missing").Int(item.Any().Span().Class("none")); v != 0 {
+			t.Fatal(c.name, "missing:", v)
+		}
+		if v := x.Field("missing attr").Attr(item.Any().A(), "title"); v != "" {
+			t.Fatal(c.name, "missing attr:", v)
+		}
+		if v := x.Field("bad").Int(item.Any().Span().Class("bad")); v != 0 {
+			t.Fatal(c.name, "bad:", v)
+		}
+		x.Field("missing source").Text(Source.File(filepath.Join(t.TempDir(), "none.html")))
+		if c.opts != nil {
+			x.Required("required").Text(item.Any().Table())
+			x.Optional("optional").Text(item.Any().Table())
+		}
+		var names []string
+		if err := x.Err(); err != nil {
+			for _, fe := range err.(ExtractErrors) {
+				names = append(names, fe.Field)
+			}
+		}
+		if len(names) != len(c.fields) {
+			t.Fatalf("%s: failed fields %q, want %q", c.name, names, c.fields)
+		}
+		for i, name := range names {
+			if name != c.fields[i] {
+				t.Fatalf("%s: failed fields %q, want %q", c.name, names, c.fields)
+			}
+		}
+	}
+
+	x := NewExtractor(nil)
+	if x.Text(item.Any().H2()) != "Title" || x.Attr(item.Any().A(), "href") != "/x" || x.Err() != nil {
+		t.Fatal("no errors:", x.Err())
+	}
+}
+
+func TestExtractorErrors(t *testing.T) {
+	item := Source.String(extractPage).Any().Div().Class("item")
+	x := NewExtractor(nil)
+	x.Field("missing").Text(item.Any().Span().Class("none"))
+	x.Int(item.Any().Span().Class("bad"))
+	errs, ok := x.Err().(ExtractErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatal(x.Err())
+	}
+	if !errors.Is(errs[0], ErrNotFound) || errs[0].Path != "/html/body/div" {
+		t.Error("missing:", errs[0], errs[0].Path)
+	}
+	if msg := errs[0].Error(); !strings.HasPrefix(msg, `field "missing": no such file or directory: `) {
+		t.Error("message:", msg)
+	}
+	if errs[1].Field != "" || errs[1].Path != "/html/body/div/span[3]" || errs[1].Error() != errs[1].Err.Error() {
+		t.Error("unnamed:", errs[1], errs[1].Path)
+	}
+}
+
+func TestExtractorExactlyOne(t *testing.T) {
+	item := Source.String(extractPage).Any().Div().Class("item")
+	x := NewExtractor(&ExtractOptions{ExactlyOne: true, Optional: true})
+	if v := x.Field("price").Int(item.Any().Span().Class("price")); v != 0 {
+		t.Fatal("price:", v)
+	}
+	if v := x.Field("title").Text(item.Any().H2()); v != "Title" {
+		t.Fatal("title:", v)
+	}
+	x.Field("missing").Float(item.Any().Span().Class("none"))
+	errs, _ := x.Err().(ExtractErrors)
+	if len(errs) != 1 || errs[0].Field != "price" || !errors.Is(errs[0], ErrTooManyNodes) {
+		t.Fatal(x.Err())
+	}
+	if errs[0].Path != "/html/body/div/span[2]" {
+		t.Fatal("path:", errs[0].Path)
+	}
+}
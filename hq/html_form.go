@@ -0,0 +1,479 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// ErrUnsupportedEnctype - unsupported form enctype
+	ErrUnsupportedEnctype = errors.New("unsupported form enctype")
+)
+
+const (
+	// EnctypeURLEncoded - form enctype `application/x-www-form-urlencoded`
+	EnctypeURLEncoded = "application/x-www-form-urlencoded"
+	// EnctypeMultipart - form enctype `multipart/form-data`
+	EnctypeMultipart = "multipart/form-data"
+)
+
+// -----------------------------------------------------------------------------
+
+// FormOption - an option of a select control.
+type FormOption struct {
+	Value    string
+	Text     string
+	Selected bool
+}
+
+// FormField - a control of a form: input, select, textarea or button.
+type FormField struct {
+	Node     *html.Node
+	Name     string
+	Type     string // input type, or "select", "textarea", "button"
+	Value    string
+	Checked  bool // for checkbox and radio
+	Multiple bool // for select
+	Disabled bool
+	Options  []*FormOption // for select
+
+	fileName string
+	fileData []byte
+}
+
+// Form - a html form.
+type Form struct {
+	Node    *html.Node
+	Action  string // resolved against the source url
+	Method  string // "GET" or "POST"
+	Enctype string
+	Fields  []*FormField
+}
+
+// Forms returns all forms in the node set (including forms of their descendants).
+func (p NodeSet) Forms() (forms []*Form, err error) {
+	nodes, err := p.Any().Element(atom.Form).Collect()
+	if err != nil {
+		return
+	}
+	forms = make([]*Form, len(nodes))
+	for i, node := range nodes {
+		forms[i] = NewForm(node)
+	}
+	return
+}
+
+// Form returns the first form in the node set.
+func (p NodeSet) Form() (form *Form, err error) {
+	node, err := p.Any().Element(atom.Form).CollectOne()
+	if err != nil {
+		return
+	}
+	return NewForm(node), nil
+}
+
+// NewForm creates a form model from a form node.
+func NewForm(node *html.Node) *Form {
+	method := strings.ToUpper(attrOr(node, "method", "GET"))
+	if method != "POST" {
+		method = "GET"
+	}
+	enctype := strings.ToLower(attrOr(node, "enctype", EnctypeURLEncoded))
+	if enctype != EnctypeMultipart {
+		enctype = EnctypeURLEncoded
+	}
+	action, _ := AttributeVal(node, "action")
+	form := &Form{
+		Node:    node,
+		Action:  ResolveURL(node, strings.TrimSpace(action)),
+		Method:  method,
+		Enctype: enctype,
+	}
+	form.collectFields(node)
+	return form
+}
+
+// collectFields collects fields owned by the form in tree order: fields in
+// the form without a `form` attribute, and fields anywhere in the document
+// whose `form` attribute is the id of the form.
+func (p *Form) collectFields(node *html.Node) {
+	id, _ := AttributeVal(node, "id")
+	if id == "" {
+		p.collectOwnedFields(node, "", true)
+	} else {
+		p.collectOwnedFields(Root(node), id, node.Parent == nil)
+	}
+}
+
+func (p *Form) collectOwnedFields(node *html.Node, id string, inForm bool) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		in := inForm || child == p.Node
+		if field := newFormField(child); field != nil {
+			owned := in
+			if owner, err := AttributeVal(child, "form"); err == nil { // owned by the form it specifies
+				owned = id != "" && owner == id
+			}
+			if owned {
+				p.Fields = append(p.Fields, field)
+			}
+			continue
+		}
+		p.collectOwnedFields(child, id, in)
+	}
+}
+
+func newFormField(node *html.Node) *FormField {
+	field := &FormField{Node: node}
+	switch node.DataAtom {
+	case atom.Input:
+		field.Type = strings.ToLower(attrOr(node, "type", "text"))
+		switch field.Type {
+		case "checkbox", "radio":
+			field.Value = attrOr(node, "value", "on")
+			field.Checked = hasAttr(node, "checked")
+		default:
+			field.Value = attrOr(node, "value", "")
+		}
+	case atom.Button:
+		field.Type = strings.ToLower(attrOr(node, "type", "submit"))
+		field.Value = attrOr(node, "value", "")
+	case atom.Textarea:
+		field.Type = "textarea"
+		field.Value = rawText(node)
+	case atom.Select:
+		field.Type = "select"
+		field.Multiple = hasAttr(node, "multiple")
+		collectOptions(node, field)
+		if !field.Multiple && len(field.Options) > 0 && field.selected() == nil {
+			field.Options[0].Selected = true
+		}
+	default:
+		return nil
+	}
+	field.Name = attrOr(node, "name", "")
+	field.Disabled = hasAttr(node, "disabled")
+	return field
+}
+
+func collectOptions(node *html.Node, field *FormField) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.DataAtom {
+		case atom.Option:
+			text := Text(child)
+			field.Options = append(field.Options, &FormOption{
+				Value:    attrOr(child, "value", text),
+				Text:     text,
+				Selected: hasAttr(child, "selected"),
+			})
+		case atom.Optgroup:
+			collectOptions(child, field)
+		}
+	}
+}
+
+func rawText(node *html.Node) string {
+	var b strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			b.WriteString(child.Data)
+		}
+	}
+	return b.String()
+}
+
+func (p *FormField) selected() *FormOption {
+	for _, opt := range p.Options {
+		if opt.Selected {
+			return opt
+		}
+	}
+	return nil
+}
+
+// Field returns the first field named name.
+func (p *Form) Field(name string) (field *FormField, err error) {
+	for _, field = range p.Fields {
+		if field.Name == name {
+			return
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Set sets value of the field named name.
+// For checkbox and radio fields, it checks the one whose value is equal to value.
+// For select fields, it selects the option whose value is equal to value.
+// If no field matches, it returns ErrNotFound and the form isn't changed.
+func (p *Form) Set(name, value string) error {
+	if !p.matches(name, value) {
+		return ErrNotFound
+	}
+	for _, field := range p.Fields {
+		if field.Name != name {
+			continue
+		}
+		switch field.Type {
+		case "checkbox":
+			if field.Value == value {
+				field.Checked = true
+			}
+		case "radio":
+			field.Checked = field.Value == value
+		case "select":
+			if field.option(value) == nil {
+				continue
+			}
+			for _, opt := range field.Options {
+				if opt.Value == value {
+					opt.Selected = true
+				} else if !field.Multiple {
+					opt.Selected = false
+				}
+			}
+		case "submit", "reset", "button", "image", "file":
+		default:
+			field.Value = value
+			return nil
+		}
+	}
+	return nil
+}
+
+// SetChecked checks or unchecks the checkbox or radio field named name whose
+// value is equal to value, or selects or deselects the option of a select
+// field whose value is equal to value. Checking a radio unchecks others of
+// its group, but selecting an option of a multiple select keeps others.
+// If no field matches, it returns ErrNotFound and the form isn't changed.
+func (p *Form) SetChecked(name, value string, checked bool) error {
+	found := false
+	for _, field := range p.Fields {
+		if field.Name != name {
+			continue
+		}
+		switch field.Type {
+		case "checkbox", "radio", "select":
+			found = found || field.matches(value)
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	for _, field := range p.Fields {
+		if field.Name != name {
+			continue
+		}
+		switch field.Type {
+		case "checkbox":
+			if field.Value == value {
+				field.Checked = checked
+			}
+		case "radio":
+			if checked {
+				field.Checked = field.Value == value
+			} else if field.Value == value {
+				field.Checked = false
+			}
+		case "select":
+			if field.option(value) == nil {
+				continue
+			}
+			for _, opt := range field.Options {
+				if opt.Value == value {
+					opt.Selected = checked
+				} else if checked && !field.Multiple {
+					opt.Selected = false
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matches checks if Set(name, value) changes any field.
+func (p *Form) matches(name, value string) bool {
+	for _, field := range p.Fields {
+		if field.Name == name && field.matches(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks if value can be set to the field.
+func (p *FormField) matches(value string) bool {
+	switch p.Type {
+	case "checkbox", "radio":
+		return p.Value == value
+	case "select":
+		return p.option(value) != nil
+	case "submit", "reset", "button", "image", "file":
+		return false
+	}
+	return true
+}
+
+func (p *FormField) option(value string) *FormOption {
+	for _, opt := range p.Options {
+		if opt.Value == value {
+			return opt
+		}
+	}
+	return nil
+}
+
+// SetFile sets content of the file field named name.
+func (p *Form) SetFile(name, fileName string, data []byte) error {
+	for _, field := range p.Fields {
+		if field.Name == name && field.Type == "file" {
+			field.fileName, field.fileData = fileName, data
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (p *FormField) successful() bool {
+	if p.Name == "" || p.Disabled {
+		return false
+	}
+	switch p.Type {
+	case "checkbox", "radio":
+		return p.Checked
+	case "submit", "reset", "button", "image":
+		return false
+	}
+	return true
+}
+
+// Values returns names and values of all successful controls (file fields excluded).
+func (p *Form) Values() url.Values {
+	vals := make(url.Values)
+	for _, field := range p.Fields {
+		if !field.successful() || field.Type == "file" {
+			continue
+		}
+		if field.Type == "select" {
+			for _, opt := range field.Options {
+				if opt.Selected {
+					vals.Add(field.Name, opt.Value)
+				}
+			}
+			continue
+		}
+		vals.Add(field.Name, field.Value)
+	}
+	return vals
+}
+
+// Request creates the http request to submit the form.
+func (p *Form) Request() (req *http.Request, err error) {
+	vals := p.Values()
+	if p.Method == "GET" {
+		u, err := url.Parse(p.Action)
+		if err != nil {
+			return nil, err
+		}
+		u.RawQuery = vals.Encode()
+		return http.NewRequest("GET", u.String(), nil)
+	}
+	var body io.Reader
+	var contentType string
+	switch p.Enctype {
+	case EnctypeURLEncoded:
+		body, contentType = strings.NewReader(vals.Encode()), EnctypeURLEncoded
+	case EnctypeMultipart:
+		var b bytes.Buffer
+		if contentType, err = p.writeMultipart(&b); err != nil {
+			return
+		}
+		body = &b
+	default:
+		return nil, ErrUnsupportedEnctype
+	}
+	if req, err = http.NewRequest("POST", p.Action, body); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", contentType)
+	return
+}
+
+func (p *Form) writeMultipart(w io.Writer) (contentType string, err error) {
+	mw := multipart.NewWriter(w)
+	for _, field := range p.Fields {
+		if !field.successful() {
+			continue
+		}
+		switch field.Type {
+		case "file":
+			var fw io.Writer
+			if fw, err = mw.CreateFormFile(field.Name, field.fileName); err != nil {
+				return
+			}
+			if _, err = fw.Write(field.fileData); err != nil {
+				return
+			}
+		case "select":
+			for _, opt := range field.Options {
+				if opt.Selected {
+					if err = mw.WriteField(field.Name, opt.Value); err != nil {
+						return
+					}
+				}
+			}
+		default:
+			if err = mw.WriteField(field.Name, field.Value); err != nil {
+				return
+			}
+		}
+	}
+	if err = mw.Close(); err != nil {
+		return
+	}
+	return mw.FormDataContentType(), nil
+}
+
+// Submit submits the form by client, and returns the response document as a node set.
+// If client is nil, http.DefaultClient is used. Use SourceCreator.Submit to
+// submit it with the client and robots.txt policy of a source creator.
+func (p *Form) Submit(client *http.Client) (ret NodeSet) {
+	return SourceCreator{Client: client}.Submit(p)
+}
+
+// Submit submits form by p.Client (see SourceCreator.Do), and returns the
+// response document as a node set.
+func (p SourceCreator) Submit(form *Form) (ret NodeSet) {
+	req, err := form.Request()
+	if err != nil {
+		return NodeSet{Err: err}
+	}
+	return p.Do(nil, req)
+}
+
+// -----------------------------------------------------------------------------
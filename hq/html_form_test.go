@@ -0,0 +1,199 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const formPage = `<html><body>
+<input name="before" form="f" value="b">
+<form id="f" action="/submit" method="post">
+	<input name="q" value="x">
+	<input type="checkbox" name="c" value="1" checked>
+	<input type="checkbox" name="c" value="2">
+	<input type="radio" name="r" value="a" checked>
+	<input type="radio" name="r" value="b">
+	<select name="s"><option>one</option><option value="2" selected>two</option></select>
+	<textarea name="t">hello</textarea>
+	<input name="other" form="g" value="o">
+	<input name="dis" disabled value="1">
+	<input type="submit" name="go" value="Go">
+	<input type="file" name="up">
+</form>
+<input name="after" form="f" value="a">
+</body></html>`
+
+func TestFormValues(t *testing.T) {
+	form, err := Source.String(formPage).Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, field := range form.Fields {
+		names = append(names, field.Name)
+	}
+	want := []string{"before", "q", "c", "c", "r", "r", "s", "t", "dis", "go", "up", "after"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("fields: got %v, want %v", names, want)
+	}
+	vals := form.Values()
+	wantVals := url.Values{
+		"before": {"b"}, "q": {"x"}, "c": {"1"}, "r": {"a"}, "s": {"2"}, "t": {"hello"}, "after": {"a"},
+	}
+	if !reflect.DeepEqual(vals, wantVals) {
+		t.Fatalf("values: got %v, want %v", vals, wantVals)
+	}
+}
+
+func TestFormSet(t *testing.T) {
+	form, err := Source.String(formPage).Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := form.Values().Encode()
+	for _, name := range []string{"s", "r", "c", "nope"} {
+		if err := form.Set(name, "zzz"); err != ErrNotFound {
+			t.Fatalf("Set(%q): got %v, want ErrNotFound", name, err)
+		}
+	}
+	if after := form.Values().Encode(); after != before {
+		t.Fatalf("unmatched Set changed the form: %s => %s", before, after)
+	}
+	if err := form.Set("r", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Set("s", "one"); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Set("c", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.SetChecked("c", "1", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.SetChecked("c", "3", false); err != ErrNotFound {
+		t.Fatal("SetChecked of an unknown value:", err)
+	}
+	if err := form.Set("q", "a b"); err != nil {
+		t.Fatal(err)
+	}
+	vals := form.Values()
+	if vals.Get("r") != "b" || vals.Get("s") != "one" || !reflect.DeepEqual(vals["c"], []string{"2"}) || vals.Get("q") != "a b" {
+		t.Fatal("values:", vals)
+	}
+}
+
+func TestFormSubmit(t *testing.T) {
+	var got url.Values
+	var file string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			io.WriteString(w, formPage)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+			t.Error(err)
+		}
+		got, file = r.Form, ""
+		if r.MultipartForm != nil {
+			if fhs := r.MultipartForm.File["up"]; len(fhs) == 1 {
+				f, _ := fhs[0].Open()
+				b, _ := io.ReadAll(f)
+				file = fhs[0].Filename + ":" + string(b)
+			}
+		}
+		io.WriteString(w, "<html><body><h1>"+r.Method+"</h1></body></html>")
+	}))
+	defer srv.Close()
+
+	form, err := Source.HTTP(srv.URL + "/page").Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Action != srv.URL+"/submit" {
+		t.Fatal("action:", form.Action)
+	}
+	for _, c := range []struct{ method, enctype string }{
+		{"POST", EnctypeURLEncoded}, {"POST", EnctypeMultipart}, {"GET", EnctypeURLEncoded},
+	} {
+		form.Method, form.Enctype = c.method, c.enctype
+		form.SetFile("up", "a.txt", []byte("data"))
+		method, err := form.Submit(srv.Client()).Any().H1().Text()
+		if err != nil || method != c.method {
+			t.Fatal(c, method, err)
+		}
+		if got.Get("q") != "x" || got.Get("after") != "a" || got.Get("go") != "" {
+			t.Fatal(c, got)
+		}
+		if wantFile := c.enctype == EnctypeMultipart; wantFile != (file == "a.txt:data") {
+			t.Fatal(c, "file:", file)
+		}
+	}
+}
+
+func TestSourceSubmit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			io.WriteString(w, "User-agent: *\nDisallow: /no\n")
+			return
+		}
+		io.WriteString(w, "<h1>"+r.UserAgent()+"</h1>")
+	}))
+	defer srv.Close()
+
+	form, err := Source.String(formPage).Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := SourceCreator{Client: srv.Client(), Robots: NewRobots("formbot")}
+	form.Action = srv.URL + "/yes"
+	if agent, err := src.Submit(form).Any().H1().Text(); err != nil || agent != "formbot" {
+		t.Fatal("allowed:", agent, err)
+	}
+	form.Action = srv.URL + "/no"
+	if err := src.Submit(form).Err; err != ErrDisallowedByRobots {
+		t.Fatal("disallowed:", err)
+	}
+	if err := form.Submit(srv.Client()).Err; err != nil { // Form.Submit has no robots.txt policy
+		t.Fatal(err)
+	}
+}
+
+func TestFormRequest(t *testing.T) {
+	form, err := Source.String(formPage).Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	form.Action, form.Method = "http://example.com/s?x=1", "GET"
+	req, err := form.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "GET" || req.Body != nil || !strings.HasPrefix(req.URL.String(), "http://example.com/s?") {
+		t.Fatal(req.Method, req.URL)
+	}
+	if req.URL.Query().Get("t") != "hello" {
+		t.Fatal(req.URL.RawQuery)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrFrozen - document is frozen
+	ErrFrozen = errors.New("document is frozen")
+)
+
+// -----------------------------------------------------------------------------
+// Concurrency
+//
+// A document may be queried by many goroutines at the same time, as long as
+// nobody mutates it. Queries (Any, Child, Match, axes, etc.), Collect,
+// CollectOne, ForEach, Text, Int, AttrVal, Render, InnerHTML, Explain, Query.Run and lookups
+// of an indexed document (see Index) only read the document. A NodeSet itself
+// is immutable and may be shared too:
+//   - A node set of Paginate keeps no state between enumerations, so it can be
+//     visited concurrently, but each enumeration fetches pages again.
+//   - A node set of NewStreamSource can be visited only once. Other visits
+//     (concurrent or not) fail with ErrStreamConsumed, so Cache it to share.
+//   - Robots is safe for concurrent use, and Check of the same host waits for
+//     Crawl-delay one by one.
+//
+// The only operations which write the document are mutations (SetAttr,
+// Remove, ReplaceWith, Sanitize, etc.) and ChildrenAsText(true). Freeze a
+// document before sharing it, so that they can't break concurrent readers.
+
+// Frozen checks if the document which node belongs to is frozen.
+func Frozen(node *html.Node) bool {
+	state := docStateOf(node, false)
+	return state != nil && state.frozen.Load()
+}
+
+// Freeze makes documents of all nodes read-only, and returns the node set
+// unchanged. Mutations of a frozen document (including ChildrenAsText(true))
+// fail with ErrFrozen and leave it untouched. So a frozen document is safe for
+// concurrent use, eg.
+//
+//	doc := hq.Source.File("a.html").Index().Freeze()
+//	items, err := doc.Any().Div().ContainsClass("item").ParallelMap(0, fn, nil)
+//
+// Freeze must be called before the documents are shared, since mutations
+// made before it may still race with readers. It returns ErrInvalidNode if a
+// node doesn't belong to a document. A document can't be unfrozen.
+func (p NodeSet) Freeze() (ret NodeSet) {
+	nodes, err := p.Collect()
+	if err != nil {
+		return NodeSet{Err: err}
+	}
+	for _, node := range nodes {
+		if Root(node).Type != html.DocumentNode {
+			return NodeSet{Err: p.nodeError(node, "Freeze", ErrInvalidNode)}
+		}
+	}
+	for _, node := range nodes {
+		docStateOf(node, true).frozen.Store(true)
+	}
+	return p
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,99 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	doc := Source.String(`<div class="item"><p>a<b>b</b></p></div>`).Freeze()
+	if err := doc.Err; err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Any().P().Remove().Err; !errors.Is(err, ErrFrozen) {
+		t.Fatal("Remove:", err)
+	}
+	if err := doc.Any().Div().AddClass("x").Err; !errors.Is(err, ErrFrozen) {
+		t.Fatal("AddClass:", err)
+	}
+	if err := doc.Any().P().ChildrenAsText(true).Err; !errors.Is(err, ErrFrozen) {
+		t.Fatal("ChildrenAsText:", err)
+	}
+	if text, err := doc.Any().P().ChildrenAsText(false).Text(); err != nil || text != "a b" {
+		t.Fatal("ChildrenAsText(false):", text, err)
+	}
+	if nodes, _ := doc.Any().B().Collect(); len(nodes) != 1 {
+		t.Fatal("document is changed")
+	}
+}
+
+// TestConcurrentReads should be run with -race.
+func TestConcurrentReads(t *testing.T) {
+	for _, name := range []string{"plain", "indexed"} {
+		doc := Source.String(largePage(200))
+		if name == "indexed" {
+			doc = doc.Index()
+		}
+		doc = doc.Freeze()
+		items := doc.Any().Li().ContainsClass("item")
+		wantItems, _ := items.Collect()
+		wantText, _ := doc.Any().Attribute("id", "i42").Text()
+
+		var wg sync.WaitGroup
+		errs := make(chan string, 3*8)
+		for i := 0; i < 8; i++ {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				nodes, err := items.Collect()
+				if err != nil || len(nodes) != len(wantItems) || nodes[42] != wantItems[42] {
+					errs <- "Collect"
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				n := 0
+				doc.Any(AnyInnermost).A().ForEach(func(node NodeSet) { n++ })
+				if n != 200+20 {
+					errs <- "Any"
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				text, err := doc.Any().Li().Attribute("id", "i42").Text()
+				if err != nil || text != wantText {
+					errs <- "Text: " + text
+				}
+				if err := doc.Any().Li().ChildrenAsText(true).Err; !errors.Is(err, ErrFrozen) {
+					errs <- "ChildrenAsText"
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("%s: %s", name, err)
+		}
+		if len(wantItems) != 200+20 || !strings.Contains(wantText, "item 42") {
+			t.Fatal("want:", len(wantItems), wantText)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// -----------------------------------------------------------------------------
+
+// indexKind - kind of an index key.
+type indexKind int
+
+const (
+	indexNone indexKind = iota
+	indexID
+	indexClass
+	indexTag // lowercased tag name
+)
+
+// indexKey - a key of the document index which a predicate can be answered by.
+// The index returns a superset of matched nodes, which are filtered by the
+// predicate then.
+type indexKey struct {
+	kind indexKind
+	val  string
+}
+
+// docIndex maps ids, classes and tags to elements of a document in document order.
+type docIndex struct {
+	mu      sync.Mutex
+	doc     *html.Node
+	gen     int64 // generation of the document when the index was built, see docState
+	built   bool
+	ids     map[string][]*html.Node
+	classes map[string][]*html.Node
+	tags    map[string][]*html.Node
+}
+
+// lookup returns elements of key. It rebuilds the index if the document has
+// been mutated since it was built.
+func (p *docIndex) lookup(key indexKey) []*html.Node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if gen := docGeneration(p.doc); !p.built || gen != p.gen {
+		p.build()
+		p.gen, p.built = gen, true
+	}
+	switch key.kind {
+	case indexID:
+		return p.ids[key.val]
+	case indexClass:
+		return p.classes[key.val]
+	}
+	return p.tags[key.val]
+}
+
+func (p *docIndex) build() {
+	p.ids = make(map[string][]*html.Node)
+	p.classes = make(map[string][]*html.Node)
+	p.tags = make(map[string][]*html.Node)
+	subtreeForEach(p.doc, func(node *html.Node) error {
+		if node.Type != html.ElementNode {
+			return nil
+		}
+		tag := strings.ToLower(node.Data)
+		p.tags[tag] = append(p.tags[tag], node)
+		if id, err := AttributeVal(node, "id"); err == nil {
+			p.ids[id] = append(p.ids[id], node)
+		}
+		if class, err := AttributeVal(node, "class"); err == nil {
+			classes := splitClasses(class)
+			for i, v := range classes {
+				if !containsString(classes[:i], v) { // class="a a"
+					p.classes[v] = append(p.classes[v], node)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// indexedDoc - an indexed document as a node set.
+type indexedDoc struct {
+	index *docIndex
+}
+
+func (p *indexedDoc) ForEach(filter func(node *html.Node) error) {
+	filter(p.index.doc)
+}
+
+func (p *indexedDoc) Cached() int {
+	return 1
+}
+
+// candidates returns elements answering the predicate with the fewest
+// candidates. ok is false if none of preds can be answered by the index.
+func (p *indexedDoc) candidates(preds []predicate) (nodes []*html.Node, ok bool) {
+	for _, pred := range preds {
+		if pred.key.kind == indexNone {
+			continue
+		}
+		if v := p.index.lookup(pred.key); !ok || len(v) < len(nodes) {
+			nodes, ok = v, true
+		}
+	}
+	return
+}
+
+// Index builds an index of the document, which maps ids, classes and tags to
+// elements. ID, ContainsClass, Element and element shortcuts (eg. Div) after
+// `Any()` use the index when the node set is an indexed document, eg.
+//
+//	doc := hq.Source.File("a.html").Index()
+//	doc.Any().Div().ContainsClass("item") // looked up by the index
+//
+// The index is built lazily, and rebuilt after the document is mutated by
+// methods of NodeSet (eg. SetAttr, Remove). Mutations of html.Node made
+// directly aren't tracked. If the node set isn't a document, Index returns
+// it unchanged.
+func (p NodeSet) Index() (ret NodeSet) {
+	if _, ok := p.Data.(*indexedDoc); ok || p.Err != nil {
+		return p
+	}
+	node, err := p.collectOne(true)
+	if err != nil || node.Type != html.DocumentNode {
+		return p
+	}
+	return NodeSet{Data: &indexedDoc{&docIndex{doc: node}}}
+}
+
+// -----------------------------------------------------------------------------
+
+// docGeneration returns the number of mutations of doc.
+func docGeneration(doc *html.Node) int64 {
+	if state := docStateOf(doc, false); state != nil {
+		return state.gen.Load()
+	}
+	return 0
+}
+
+// touchDocument marks the document which node belongs to as mutated.
+func touchDocument(node *html.Node) {
+	if state := docStateOf(node, true); state != nil {
+		state.gen.Add(1)
+	}
+}
+
+// -----------------------------------------------------------------------------
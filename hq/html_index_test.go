@@ -0,0 +1,80 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	const page = `<div id="a" class="item x"><p class="item"><a id="l1">1</a></p></div>
+<div class="x  x item"><a id="l2" class="item">2</a></div><DIV id="b"><a>3</a></DIV><div id="a"></div>`
+	chains := map[string]func(doc NodeSet) NodeSet{
+		"tag":            func(doc NodeSet) NodeSet { return doc.Any().Div() },
+		"class":          func(doc NodeSet) NodeSet { return doc.Any().ContainsClass("item") },
+		"duplicated id":  func(doc NodeSet) NodeSet { return doc.Any().Attribute("id", "a") },
+		"tag and class":  func(doc NodeSet) NodeSet { return doc.Any().Div().ContainsClass("x") },
+		"element":        func(doc NodeSet) NodeSet { return doc.Any().Element("div") },
+		"missing class":  func(doc NodeSet) NodeSet { return doc.Any().A().ContainsClass("none") },
+		"not indexable":  func(doc NodeSet) NodeSet { return doc.Any().HasAttr("id") },
+		"following step": func(doc NodeSet) NodeSet { return doc.Any().Div().ContainsClass("item").Child().A() },
+	}
+	for name, chain := range chains {
+		want := nodeIDs(t, chain(Source.String(page)))
+		doc := Source.String(page).Index()
+		if got := nodeIDs(t, chain(doc)); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+		if name == "tag and class" && !strings.Contains(chain(doc).Explain(), "Document(indexed)") {
+			t.Errorf("%s: not indexed:\n%s", name, chain(doc).Explain())
+		}
+	}
+}
+
+func TestIndexInvalidated(t *testing.T) {
+	doc := Source.String(`<div class="item" id="d1"><a id="l1"></a></div><div id="d2"><a id="l2"></a></div>`).Index()
+	items := doc.Any().Div().ContainsClass("item")
+	if got := nodeIDs(t, items); got != "d1" {
+		t.Fatal("before:", got)
+	}
+	if err := doc.Any().Div().Attribute("id", "d2").AddClass("item").Err; err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeIDs(t, items); got != "d1 d2" {
+		t.Fatal("after AddClass:", got)
+	}
+	if err := doc.Any().Attribute("id", "d1").Remove().Err; err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeIDs(t, items); got != "d2" {
+		t.Fatal("after Remove:", got)
+	}
+	if got := nodeIDs(t, doc.Any().A()); got != "l2" {
+		t.Fatal("after Remove:", got)
+	}
+	node, err := doc.Any().A().CollectOne()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gen := docGeneration(Root(node)); gen != 2 {
+		t.Fatal("generation:", gen)
+	}
+	if doc.Freeze(); !Frozen(node) || len(Root(node).Attr) != 0 {
+		t.Fatal("state is stored in the document:", Root(node).Attr)
+	}
+}
@@ -0,0 +1,270 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// -----------------------------------------------------------------------------
+
+// mutate collects all nodes of the node set first (so that the enumeration
+// isn't affected by mutations), and then calls fn for each node. Documents
+// of the nodes are marked as mutated, so that their indexes are rebuilt.
+// It fails with ErrFrozen before calling fn if any document is frozen, and
+// with the error of check (if not nil) before calling fn if any node fails
+// it, so that no node is changed in both cases.
+// Errors of a node are returned as a *QueryError of operation op.
+func (p NodeSet) mutate(op string, check, fn func(node *html.Node) error) (ret NodeSet) {
+	nodes, err := p.Collect()
+	if err != nil {
+		return NodeSet{Err: err}
+	}
+	for _, node := range nodes {
+		if Frozen(node) {
+			return NodeSet{Err: p.nodeError(node, op, ErrFrozen)}
+		}
+	}
+	if check != nil {
+		for _, node := range nodes {
+			if err = check(node); err != nil {
+				return NodeSet{Err: p.nodeError(node, op, err)}
+			}
+		}
+	}
+	for _, node := range nodes {
+		touchDocument(node)
+		if err = fn(node); err != nil {
+			return NodeSet{Err: p.nodeError(node, op, err)}
+		}
+	}
+	return NodeSet{Data: &fixNodes{nodes}}
+}
+
+// parseHTML parses text as a html fragment in the context of node.
+func parseHTML(text string, context *html.Node) ([]*html.Node, error) {
+	if context == nil || context.Type != html.ElementNode {
+		context = &html.Node{Type: html.ElementNode, DataAtom: atom.Body, Data: "body"}
+	}
+	return html.ParseFragment(strings.NewReader(text), context)
+}
+
+// hasParent checks if node has a parent, which is required to replace it.
+func hasParent(node *html.Node) error {
+	if node.Parent == nil {
+		return ErrInvalidNode
+	}
+	return nil
+}
+
+func removeChildren(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = node.FirstChild {
+		node.RemoveChild(child)
+	}
+}
+
+// Remove removes all nodes from their parents, and returns them as a node set.
+func (p NodeSet) Remove() (ret NodeSet) {
+	return p.mutate("Remove", nil, func(node *html.Node) error {
+		if node.Parent != nil {
+			node.Parent.RemoveChild(node)
+		}
+		return nil
+	})
+}
+
+// ReplaceWith replaces each node with nodes parsed from html text, and returns
+// the new nodes as a node set.
+func (p NodeSet) ReplaceWith(text string) (ret NodeSet) {
+	var added []*html.Node
+	ret = p.mutate("ReplaceWith", hasParent, func(node *html.Node) error {
+		parent := node.Parent
+		if parent == nil { // removed by a previous node, e.g. a duplicated one
+			return ErrInvalidNode
+		}
+		nodes, err := parseHTML(text, parent)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			parent.InsertBefore(n, node)
+		}
+		parent.RemoveChild(node)
+		added = append(added, nodes...)
+		return nil
+	})
+	if ret.Err != nil {
+		return
+	}
+	return NodeSet{Data: &fixNodes{added}}
+}
+
+// AppendHTML appends nodes parsed from html text as the last children of each node.
+func (p NodeSet) AppendHTML(text string) (ret NodeSet) {
+	return p.mutate("AppendHTML", nil, func(node *html.Node) error {
+		nodes, err := parseHTML(text, node)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			node.AppendChild(n)
+		}
+		return nil
+	})
+}
+
+// Wrap wraps each node with a new tag element, and returns the new elements as a node set.
+func (p NodeSet) Wrap(tag string) (ret NodeSet) {
+	var wrappers []*html.Node
+	ret = p.mutate(fmt.Sprintf("Wrap(%q)", tag), nil, func(node *html.Node) error {
+		wrapper := &html.Node{
+			Type:     html.ElementNode,
+			DataAtom: atom.Lookup([]byte(tag)),
+			Data:     tag,
+		}
+		if parent := node.Parent; parent != nil {
+			parent.InsertBefore(wrapper, node)
+			parent.RemoveChild(node)
+		}
+		wrapper.AppendChild(node)
+		wrappers = append(wrappers, wrapper)
+		return nil
+	})
+	if ret.Err != nil {
+		return
+	}
+	return NodeSet{Data: &fixNodes{wrappers}}
+}
+
+// Unwrap replaces each node with its children, and returns the removed nodes as a node set.
+func (p NodeSet) Unwrap() (ret NodeSet) {
+	return p.mutate("Unwrap", hasParent, func(node *html.Node) error {
+		parent := node.Parent
+		if parent == nil { // removed by a previous node, e.g. a duplicated one
+			return ErrInvalidNode
+		}
+		for child := node.FirstChild; child != nil; child = node.FirstChild {
+			node.RemoveChild(child)
+			parent.InsertBefore(child, node)
+		}
+		parent.RemoveChild(node)
+		return nil
+	})
+}
+
+// SetText replaces children of each node with a text node.
+func (p NodeSet) SetText(text string) (ret NodeSet) {
+	return p.mutate("SetText", nil, func(node *html.Node) error {
+		if node.Type == html.TextNode {
+			node.Data = text
+			return nil
+		}
+		removeChildren(node)
+		node.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+		return nil
+	})
+}
+
+// SetAttr sets attribute k's value of each element node.
+func (p NodeSet) SetAttr(k, v string) (ret NodeSet) {
+	k = strings.ToLower(k)
+	return p.mutate(fmt.Sprintf("SetAttr(%q)", k), nil, func(node *html.Node) error {
+		if node.Type == html.ElementNode {
+			setAttr(node, k, v)
+		}
+		return nil
+	})
+}
+
+// RemoveAttr removes attribute k of each element node.
+func (p NodeSet) RemoveAttr(k string) (ret NodeSet) {
+	k = strings.ToLower(k)
+	return p.mutate(fmt.Sprintf("RemoveAttr(%q)", k), nil, func(node *html.Node) error {
+		removeAttr(node, k)
+		return nil
+	})
+}
+
+// AddClass adds class v to each element node.
+func (p NodeSet) AddClass(v string) (ret NodeSet) {
+	return p.mutate(fmt.Sprintf("AddClass(%q)", v), nil, func(node *html.Node) error {
+		if node.Type != html.ElementNode {
+			return nil
+		}
+		source, _ := AttributeVal(node, "class")
+		classes := splitClasses(source)
+		for _, class := range classes {
+			if class == v {
+				return nil
+			}
+		}
+		setAttr(node, "class", strings.Join(append(classes, v), " "))
+		return nil
+	})
+}
+
+// RemoveClass removes class v from each element node. The class attribute is
+// removed if no class is left.
+func (p NodeSet) RemoveClass(v string) (ret NodeSet) {
+	return p.mutate(fmt.Sprintf("RemoveClass(%q)", v), nil, func(node *html.Node) error {
+		source, err := AttributeVal(node, "class")
+		if err != nil {
+			return nil
+		}
+		classes := splitClasses(source)
+		n := 0
+		for _, class := range classes {
+			if class != v {
+				classes[n] = class
+				n++
+			}
+		}
+		if n == 0 {
+			removeAttr(node, "class")
+		} else {
+			setAttr(node, "class", strings.Join(classes[:n], " "))
+		}
+		return nil
+	})
+}
+
+func setAttr(node *html.Node, k, v string) {
+	for i, attr := range node.Attr {
+		if attr.Key == k && attr.Namespace == "" {
+			node.Attr[i].Val = v
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: k, Val: v})
+}
+
+func removeAttr(node *html.Node, k string) {
+	n := 0
+	for _, attr := range node.Attr {
+		if attr.Key != k || attr.Namespace != "" {
+			node.Attr[n] = attr
+			n++
+		}
+	}
+	node.Attr = node.Attr[:n]
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,144 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// checkLinks checks Parent, FirstChild, LastChild, PrevSibling and NextSibling
+// links of node and all its descendants.
+func checkLinks(t *testing.T, node *html.Node) {
+	t.Helper()
+	var prev *html.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Parent != node || child.PrevSibling != prev {
+			t.Fatalf("bad links of <%s> in <%s>", child.Data, node.Data)
+		}
+		checkLinks(t, child)
+		prev = child
+	}
+	if node.LastChild != prev {
+		t.Fatalf("bad LastChild of <%s>", node.Data)
+	}
+}
+
+// checkDetached checks if nodes of ns are detached from their documents.
+func checkDetached(t *testing.T, ns NodeSet) {
+	t.Helper()
+	nodes, err := ns.Collect()
+	if err != nil || len(nodes) == 0 {
+		t.Fatal("Collect:", len(nodes), err)
+	}
+	for _, node := range nodes {
+		if node.Parent != nil || node.PrevSibling != nil || node.NextSibling != nil {
+			t.Fatalf("<%s> isn't detached", node.Data)
+		}
+		checkLinks(t, node)
+	}
+}
+
+const mutatePage = `<div id="d"><p id="a">a</p><p id="b" class="x  y">b</p><p id="c" class="x">c</p></div>`
+
+func TestMutate(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(doc NodeSet) NodeSet
+		want   string
+		ret    string // ids of the returned nodes
+	}{
+		{"Remove", func(doc NodeSet) NodeSet {
+			ret := doc.Any().Attribute("id", "b").Remove()
+			checkDetached(t, ret)
+			return ret
+		}, `<div id="d"><p id="a">a</p><p id="c" class="x">c</p></div>`, "b"},
+		{"ReplaceWith", func(doc NodeSet) NodeSet {
+			return doc.Any().Attribute("id", "b").ReplaceWith(`<i id="i1">1</i><i id="i2">2</i>`)
+		}, `<div id="d"><p id="a">a</p><i id="i1">1</i><i id="i2">2</i><p id="c" class="x">c</p></div>`, "i1 i2"},
+		{"Wrap", func(doc NodeSet) NodeSet {
+			return doc.Any().P().Attribute("id", "b").Wrap("section")
+		}, `<div id="d"><p id="a">a</p><section><p id="b" class="x  y">b</p></section><p id="c" class="x">c</p></div>`, "section"},
+		{"Unwrap", func(doc NodeSet) NodeSet {
+			ret := doc.Any().Attribute("id", "b").Unwrap()
+			checkDetached(t, ret)
+			return ret
+		}, `<div id="d"><p id="a">a</p>b<p id="c" class="x">c</p></div>`, "b"},
+		{"Unwrap div", func(doc NodeSet) NodeSet {
+			return doc.Any().Div().Unwrap()
+		}, `<p id="a">a</p><p id="b" class="x  y">b</p><p id="c" class="x">c</p>`, "d"},
+		{"SetText", func(doc NodeSet) NodeSet {
+			return doc.Any().Div().SetText("<t>")
+		}, `<div id="d">&lt;t&gt;</div>`, "d"},
+		{"AppendHTML", func(doc NodeSet) NodeSet {
+			return doc.Any().Div().AppendHTML(`<b>1</b>2`)
+		}, `<div id="d"><p id="a">a</p><p id="b" class="x  y">b</p><p id="c" class="x">c</p><b>1</b>2</div>`, "d"},
+		{"AddClass", func(doc NodeSet) NodeSet {
+			return doc.Any().P().AddClass("y")
+		}, `<div id="d"><p id="a" class="y">a</p><p id="b" class="x  y">b</p><p id="c" class="x y">c</p></div>`, "a b c"},
+		{"RemoveClass", func(doc NodeSet) NodeSet {
+			return doc.Any().P().RemoveClass("x")
+		}, `<div id="d"><p id="a">a</p><p id="b" class="y">b</p><p id="c">c</p></div>`, "a b c"},
+		{"SetAttr", func(doc NodeSet) NodeSet {
+			return doc.Any().P().Attribute("id", "a").SetAttr("Title", "t").SetAttr("id", "e")
+		}, `<div id="d"><p id="e" title="t">a</p><p id="b" class="x  y">b</p><p id="c" class="x">c</p></div>`, "e"},
+	}
+	for _, c := range cases {
+		doc := Source.String(mutatePage)
+		ret := c.mutate(doc)
+		if got := nodeIDs(t, ret); got != c.ret {
+			t.Errorf("%s: returned %q, want %q", c.name, got, c.ret)
+		}
+		body, err := doc.Any().Body().One().CollectOne()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkLinks(t, Root(body))
+		if text, err := doc.Any().Body().InnerHTML(); err != nil || text != c.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", c.name, text, c.want)
+		}
+	}
+}
+
+func TestMutateDetached(t *testing.T) {
+	for _, name := range []string{"ReplaceWith", "Unwrap"} {
+		doc := Source.String(mutatePage)
+		ns := Concat(doc.Any().P(), Source.Fragment(`<p id="f">f</p>`, ""))
+		var ret NodeSet
+		if name == "ReplaceWith" {
+			ret = ns.ReplaceWith(`<i>1</i>`)
+		} else {
+			ret = ns.Unwrap()
+		}
+		var qe *QueryError
+		if !errors.As(ret.Err, &qe) || !errors.Is(ret.Err, ErrInvalidNode) {
+			t.Fatalf("%s: %v", name, ret.Err)
+		}
+		if text, _ := doc.Any().Body().InnerHTML(); text != mutatePage {
+			t.Fatalf("%s: document is changed: %s", name, text)
+		}
+	}
+
+	wrapped := Source.Fragment(`<p id="f">f</p>`, "").Wrap("div")
+	nodes, err := wrapped.Child().Collect()
+	if err != nil || len(nodes) != 1 || nodes[0].Parent == nil || nodes[0].Parent.Data != "div" {
+		t.Fatal("Wrap:", nodes, err)
+	}
+	checkDetached(t, wrapped)
+}
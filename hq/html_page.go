@@ -0,0 +1,144 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// -----------------------------------------------------------------------------
+
+// NextPage locates the next page url of doc, which is the page-th page (from 1).
+// It returns ErrNotFound (or an error wrapping it, or an empty url) if doc is
+// the last page.
+type NextPage func(doc NodeSet, page int) (url string, err error)
+
+// NextLink returns a NextPage which takes the href of the node selected by sel
+// as the next page url.
+func NextLink(sel func(doc NodeSet) NodeSet) NextPage {
+	return func(doc NodeSet, page int) (string, error) {
+		href, err := sel(doc).HrefVal()
+		if err != nil {
+			return "", err
+		}
+		node, _ := doc.CollectOne()
+		return ResolveURL(node, strings.TrimSpace(href)), nil
+	}
+}
+
+// PageTemplate returns a NextPage which formats the next page url by
+// `fmt.Sprintf(format, page+1)`. Pagination stops when the page has no nodes
+// selected by items, or by PageOptions.MaxPages.
+func PageTemplate(format string, items func(doc NodeSet) NodeSet) NextPage {
+	return func(doc NodeSet, page int) (string, error) {
+		if items != nil {
+			if _, err := items(doc).CollectOne(); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf(format, page+1), nil
+	}
+}
+
+// PageOptions - options of Paginate.
+type PageOptions struct {
+	MaxPages int           // max pages to visit, 0 means no limit
+	Delay    time.Duration // delay between two page requests
+}
+
+// Pages - a lazily fetched page sequence, which is a NodeEnum of documents.
+// It keeps no state between enumerations, so it can be visited concurrently.
+type Pages struct {
+	src   SourceCreator
+	start string
+	next  NextPage
+	opts  PageOptions
+}
+
+// Paginate returns documents of all pages as a node set, starting from url.
+// Pages are fetched lazily while the node set is visited, so ForEach can
+// iterate across all pages transparently, and CollectOne fetches the first
+// page only. A visited url stops pagination to avoid cycles.
+//
+// Errors other than ErrNotFound that occur while visiting (eg. failing to
+// fetch a page) stop pagination, and are returned by Collect, CollectOne,
+// Render, etc. of the node set and node sets derived from it.
+func (p SourceCreator) Paginate(url string, next NextPage, opts *PageOptions) (ret NodeSet) {
+	pages := &Pages{src: p, start: url, next: next}
+	if opts != nil {
+		pages.opts = *opts
+	}
+	return NodeSet{Data: pages}
+}
+
+// ForEach visits documents of all pages. Errors are ignored, see Paginate.
+func (p *Pages) ForEach(filter func(node *html.Node) error) {
+	p.forEachErr(filter)
+}
+
+func (p *Pages) forEachErr(filter func(node *html.Node) error) error {
+	seen := make(map[string]bool)
+	uri := p.start
+	for page := 1; ; page++ {
+		key := normalizePageURL(uri)
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		if page > 1 && p.opts.Delay > 0 {
+			time.Sleep(p.opts.Delay)
+		}
+		doc := p.src.HTTP(uri)
+		node, err := doc.CollectOne()
+		if err != nil {
+			return err
+		}
+		if filter(node) == ErrBreak {
+			return nil
+		}
+		if p.opts.MaxPages > 0 && page >= p.opts.MaxPages {
+			return nil
+		}
+		if uri, err = p.next(doc, page); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			return err
+		}
+		if uri == "" {
+			return nil
+		}
+	}
+}
+
+func normalizePageURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// -----------------------------------------------------------------------------
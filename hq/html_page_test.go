@@ -0,0 +1,161 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newPageServer serves pages 1..n, each having two items and a link to the
+// next page. The last page links to the first one, to test cycles.
+func newPageServer(n int) (srv *httptest.Server, fetches *int32) {
+	fetches = new(int32)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(fetches, 1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 || page > n {
+			fmt.Fprint(w, `<p>empty</p>`)
+			return
+		}
+		next := page%n + 1
+		fmt.Fprintf(w, `<ul><li>%d-a</li><li>%d-b</li></ul><a class="next" href="?page=%d#top">next</a>`, page, page, next)
+	}))
+	return
+}
+
+func nextLink(doc NodeSet) NodeSet {
+	return doc.Any().A().ContainsClass("next")
+}
+
+func pageItems(t *testing.T, ns NodeSet) []string {
+	nodes, err := ns.Any().Li().Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make([]string, len(nodes))
+	for i, node := range nodes {
+		items[i] = Text(node)
+	}
+	return items
+}
+
+func TestPaginate(t *testing.T) {
+	srv, fetches := newPageServer(3)
+	defer srv.Close()
+
+	ns := Source.Paginate(srv.URL+"/?page=1", NextLink(nextLink), nil)
+	want := []string{"1-a", "1-b", "2-a", "2-b", "3-a", "3-b"}
+	if items := pageItems(t, ns); !reflect.DeepEqual(items, want) { // stops at the cycle
+		t.Fatal(items)
+	}
+	if n := atomic.LoadInt32(fetches); n != 3 {
+		t.Fatal("fetches:", n)
+	}
+
+	ns = Source.Paginate(srv.URL+"/?page=1", NextLink(nextLink), &PageOptions{MaxPages: 2})
+	if items := pageItems(t, ns); len(items) != 4 {
+		t.Fatal("MaxPages:", items)
+	}
+
+	tmpl := PageTemplate(srv.URL+"/?page=%d", func(doc NodeSet) NodeSet { return doc.Any().Li() })
+	ns = Source.Paginate(srv.URL+"/?page=1", tmpl, nil)
+	if items := pageItems(t, ns); !reflect.DeepEqual(items, want) { // page 4 has no items
+		t.Fatal("PageTemplate:", items)
+	}
+}
+
+func TestPaginateLazy(t *testing.T) {
+	srv, fetches := newPageServer(5)
+	defer srv.Close()
+
+	ns := Source.Paginate(srv.URL+"/?page=1", NextLink(nextLink), &PageOptions{MaxPages: 5})
+	for _, mode := range []AnyMode{AnyAll, AnyOutermost, AnyInnermost} {
+		atomic.StoreInt32(fetches, 0)
+		text, err := ns.Any(mode).Li().Text()
+		if err != nil || text != "1-a" {
+			t.Fatal(mode, text, err)
+		}
+		if n := atomic.LoadInt32(fetches); n != 1 {
+			t.Fatalf("mode %d: %d pages fetched, want 1", mode, n)
+		}
+	}
+}
+
+func TestPaginateError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<li>1</li><a class="next" href="http://127.0.0.1:1/">next</a>`)
+	}))
+	defer srv.Close()
+
+	ns := Source.Paginate(srv.URL, NextLink(nextLink), nil)
+	nodes, err := ns.Any().Li().Collect()
+	if err == nil || len(nodes) != 1 {
+		t.Fatal("Collect:", len(nodes), err)
+	}
+	if _, err = ns.Any().Span().CollectOne(); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatal("CollectOne:", err)
+	}
+	if _, err = ns.Any().Li().CollectOne(); err != nil { // the first page is fine
+		t.Fatal(err)
+	}
+}
+
+func TestPaginateConcurrent(t *testing.T) {
+	srv, _ := newPageServer(3)
+	defer srv.Close()
+
+	ns := Source.Paginate(srv.URL+"/?page=1", NextLink(nextLink), nil).Any().Li()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if nodes, err := ns.Collect(); err != nil || len(nodes) != 6 {
+				t.Error(len(nodes), err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSourceURL(t *testing.T) {
+	srv, _ := newPageServer(1)
+	defer srv.Close()
+
+	url := srv.URL + "/?page=1"
+	node, err := nextLink(Source.HTTP(url)).CollectOne()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if SourceURL(node) != url || BaseURL(node) != url || sourceName(node) != url {
+		t.Fatal("url:", SourceURL(node), BaseURL(node), sourceName(node))
+	}
+	if attrs := Root(node).Attr; len(attrs) != 0 {
+		t.Fatal("url is stored in the document:", attrs)
+	}
+	if node, _ = Source.String(`<a>1</a>`).Any().A().CollectOne(); SourceURL(node) != "" {
+		t.Fatal("url of a string source:", SourceURL(node))
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// -----------------------------------------------------------------------------
+
+// MapFunc - a function called by ParallelMap for each item.
+type MapFunc func(ctx context.Context, item NodeSet) (v interface{}, err error)
+
+// MapOptions - options of ParallelMap.
+type MapOptions struct {
+	// Context cancels the map, context.Background() if nil. Items not started
+	// yet are skipped after it's done, and ctx passed to MapFunc is done too.
+	Context context.Context
+
+	// CollectErrors makes ParallelMap run all items and return all errors as
+	// MapErrors, instead of stopping at the first error.
+	CollectErrors bool
+}
+
+// ItemError - an error of an item of ParallelMap.
+type ItemError struct {
+	Index int // from 0, index of the item
+	Err   error
+}
+
+func (p *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", p.Index, p.Err)
+}
+
+// Unwrap returns the underlying error.
+func (p *ItemError) Unwrap() error {
+	return p.Err
+}
+
+// MapErrors - errors of ParallelMap with MapOptions.CollectErrors, ordered by
+// item index.
+type MapErrors []*ItemError
+
+func (p MapErrors) Error() string {
+	msgs := make([]string, len(p))
+	for i, err := range p {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ParallelMap calls fn for each node of the node set by n goroutines (the
+// number of CPUs if n <= 0), and returns results in document order.
+//
+// If fn fails, ParallelMap cancels the ctx passed to other calls, skips items
+// not started yet, and returns the first error (an *ItemError), unless
+// opts.CollectErrors is true. If opts.Context is done, it returns the error
+// of the context. Results of items which aren't run or fail are nil.
+func (p NodeSet) ParallelMap(n int, fn MapFunc, opts *MapOptions) (results []interface{}, err error) {
+	nodes, err := p.Collect()
+	if err != nil {
+		return
+	}
+	var o MapOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancel(o.Context)
+	defer cancel()
+
+	results = make([]interface{}, len(nodes))
+	var (
+		mu   sync.Mutex
+		errs MapErrors
+		wg   sync.WaitGroup
+	)
+	jobs := make(chan int)
+	for i := 0; i < n && i < len(nodes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				v, err := fn(ctx, NodeSet{Data: oneNode{nodes[i]}})
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, &ItemError{Index: i, Err: err})
+					mu.Unlock()
+					if !o.CollectErrors {
+						cancel()
+					}
+					continue
+				}
+				results[i] = v
+			}
+		}()
+	}
+dispatch:
+	for i := range nodes {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	switch {
+	case len(errs) > 0 && !o.CollectErrors:
+		return results, errs[0]
+	case len(errs) > 0:
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Index < errs[j].Index })
+		return results, errs
+	}
+	return results, o.Context.Err()
+}
+
+// -----------------------------------------------------------------------------
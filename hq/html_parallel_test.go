@@ -0,0 +1,150 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// numberedItems returns a node set of n items, whose texts are 0..n-1.
+func numberedItems(n int) NodeSet {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "<p>%d</p>", i)
+	}
+	return Source.String(b.String()).Any().P()
+}
+
+func itemIndex(item NodeSet) int {
+	text, _ := item.Text()
+	i, _ := strconv.Atoi(strings.TrimSpace(text))
+	return i
+}
+
+// waitDone waits until ctx is done, and returns false if it times out.
+func waitDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-time.After(10 * time.Second):
+		return false
+	}
+}
+
+var errItem = errors.New("item error")
+
+func TestParallelMapOrder(t *testing.T) {
+	const n = 100
+	results, err := numberedItems(n).ParallelMap(8, func(ctx context.Context, item NodeSet) (interface{}, error) {
+		i := itemIndex(item)
+		time.Sleep(time.Duration(n-i) * 10 * time.Microsecond) // later items finish first
+		return i * 2, nil
+	}, nil)
+	if err != nil || len(results) != n {
+		t.Fatal("ParallelMap:", len(results), err)
+	}
+	for i, v := range results {
+		if v != i*2 {
+			t.Fatalf("results[%d] = %v", i, v)
+		}
+	}
+}
+
+func TestParallelMapFirstError(t *testing.T) {
+	const n = 100
+	var started, timeouts int32
+	results, err := numberedItems(n).ParallelMap(4, func(ctx context.Context, item NodeSet) (interface{}, error) {
+		atomic.AddInt32(&started, 1)
+		if i := itemIndex(item); i == 0 {
+			return nil, errItem
+		}
+		if !waitDone(ctx) { // other workers are cancelled
+			atomic.AddInt32(&timeouts, 1)
+		}
+		return 1, nil
+	}, nil)
+	var ie *ItemError
+	if !errors.As(err, &ie) || ie.Index != 0 || !errors.Is(err, errItem) {
+		t.Fatal("error:", err)
+	}
+	if results[0] != nil || len(results) != n {
+		t.Fatal("results:", len(results), results[0])
+	}
+	if s := atomic.LoadInt32(&started); s >= n || timeouts != 0 {
+		t.Fatal("remaining items aren't skipped:", s, timeouts)
+	}
+}
+
+func TestParallelMapCollectErrors(t *testing.T) {
+	const n = 20
+	results, err := numberedItems(n).ParallelMap(4, func(ctx context.Context, item NodeSet) (interface{}, error) {
+		i := itemIndex(item)
+		if i%3 == 0 {
+			time.Sleep(time.Duration(n-i) * 100 * time.Microsecond) // errors happen out of order
+			return nil, fmt.Errorf("%w %d", errItem, i)
+		}
+		return i, ctx.Err()
+	}, &MapOptions{CollectErrors: true})
+	var errs MapErrors
+	if !errors.As(err, &errs) || len(errs) != 7 {
+		t.Fatal("error:", err)
+	}
+	for k, e := range errs {
+		if e.Index != k*3 || !errors.Is(e, errItem) || e.Error() != fmt.Sprintf("item %d: item error %d", k*3, k*3) {
+			t.Fatalf("errs[%d]: %v", k, e)
+		}
+	}
+	for i, v := range results {
+		if i%3 == 0 && v != nil || i%3 != 0 && v != i {
+			t.Fatalf("results[%d] = %v", i, v)
+		}
+	}
+}
+
+func TestParallelMapContext(t *testing.T) {
+	const n = 100
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started, timeouts int32
+	results, err := numberedItems(n).ParallelMap(4, func(itemCtx context.Context, item NodeSet) (interface{}, error) {
+		atomic.AddInt32(&started, 1)
+		if itemIndex(item) == 0 {
+			cancel()
+			return 0, nil
+		}
+		if !waitDone(itemCtx) {
+			atomic.AddInt32(&timeouts, 1)
+		}
+		return nil, nil
+	}, &MapOptions{Context: ctx})
+	if err != context.Canceled {
+		t.Fatal("error:", err)
+	}
+	if len(results) != n || results[0] != 0 {
+		t.Fatal("results:", len(results), results[0])
+	}
+	if s := atomic.LoadInt32(&started); s >= n || timeouts != 0 {
+		t.Fatal("map isn't stopped:", s, timeouts)
+	}
+}
@@ -0,0 +1,306 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrNotCompilable - chain isn't compilable
+	ErrNotCompilable = errors.New("chain isn't compilable")
+)
+
+// -----------------------------------------------------------------------------
+
+// planNode is implemented by node enumerators of chain steps, so that a chain
+// can be explained and compiled.
+type planNode interface {
+	NodeEnum
+	// step returns description of the step and its input.
+	step() (desc string, input NodeEnum)
+	// withInput returns a copy of the step, whose input is replaced by input.
+	withInput(input NodeEnum) NodeEnum
+}
+
+// Explain returns the query plan of the node set, one step per line from the
+// source to the last step. Consecutive Match steps (eg. `Div().Class("x")`)
+// are fused into one step, and Match steps following an Any step are pushed
+// into the Any step.
+func (p NodeSet) Explain() string {
+	if p.Err != nil {
+		return "Error: " + p.Err.Error()
+	}
+	return explain(p.Data)
+}
+
+func explain(data NodeEnum) string {
+	var b strings.Builder
+	_, steps := chainOf(data)
+	for _, step := range steps {
+		b.WriteString(step)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// chainOf returns node enumerators of the chain ending with data and their
+// descriptions, from the source to data.
+func chainOf(data NodeEnum) (chain []NodeEnum, steps []string) {
+	for data != nil {
+		chain = append(chain, data)
+		s, ok := data.(planNode)
+		if !ok {
+			steps = append(steps, sourceDesc(data))
+			break
+		}
+		var desc string
+		desc, data = s.step()
+		steps = append(steps, desc)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return
+}
+
+func sourceDesc(data NodeEnum) string {
+	switch v := data.(type) {
+	case *queryInput:
+		return "Input"
+	case oneNode:
+		if v.Type == html.DocumentNode {
+			return "Document"
+		}
+		return "Nodes(1)"
+	case *fixNodes:
+		return fmt.Sprintf("Nodes(%d)", len(v.nodes))
+	case *indexedDoc:
+		return "Document(indexed)"
+	}
+	return fmt.Sprintf("Source(%T)", data)
+}
+
+func predicatesDesc(preds []predicate) string {
+	descs := make([]string, len(preds))
+	for i, pred := range preds {
+		descs[i] = pred.String()
+	}
+	return strings.Join(descs, " and ")
+}
+
+var anyModeNames = [...]string{AnyAll: "all", AnyOutermost: "outermost", AnyInnermost: "innermost"}
+
+func anyDesc(mode AnyMode) string {
+	if int(mode) < len(anyModeNames) {
+		return "Any(" + anyModeNames[mode] + ")"
+	}
+	return fmt.Sprintf("Any(%d)", int(mode))
+}
+
+var nodeTypeNames = map[html.NodeType]string{
+	html.ErrorNode: "error", html.TextNode: "text", html.DocumentNode: "document",
+	html.ElementNode: "element", html.CommentNode: "comment", html.DoctypeNode: "doctype",
+}
+
+func nodeTypeName(nodeType html.NodeType) string {
+	if name, ok := nodeTypeNames[nodeType]; ok {
+		return name
+	}
+	return fmt.Sprint(int(nodeType))
+}
+
+// -----------------------------------------------------------------------------
+
+func (p *matchedNodes) step() (string, NodeEnum) {
+	return "Match(" + predicatesDesc(p.preds) + ")", p.data
+}
+
+func (p *matchedNodes) withInput(input NodeEnum) NodeEnum {
+	return &matchedNodes{input, p.preds}
+}
+
+func (p *anyNodes) step() (string, NodeEnum) {
+	return anyDesc(p.mode), p.data
+}
+
+func (p *anyNodes) withInput(input NodeEnum) NodeEnum {
+	return &anyNodes{input, p.mode}
+}
+
+func (p *anyMatchedNodes) step() (string, NodeEnum) {
+	return anyDesc(p.mode) + " where " + predicatesDesc(p.preds), p.data
+}
+
+func (p *anyMatchedNodes) withInput(input NodeEnum) NodeEnum {
+	return &anyMatchedNodes{input, p.mode, p.preds}
+}
+
+func (p *childLevelNodes) step() (string, NodeEnum) {
+	if p.level == 1 {
+		return "Child", p.data
+	}
+	return fmt.Sprintf("ChildN(%d)", p.level), p.data
+}
+
+func (p *childLevelNodes) withInput(input NodeEnum) NodeEnum {
+	return &childLevelNodes{input, p.level}
+}
+
+func (p *parentLevelNodes) step() (string, NodeEnum) {
+	if p.level == -1 {
+		return "Parent", p.data
+	}
+	return fmt.Sprintf("ParentN(%d)", -p.level), p.data
+}
+
+func (p *parentLevelNodes) withInput(input NodeEnum) NodeEnum {
+	return &parentLevelNodes{input, p.level}
+}
+
+func (p *siblingNodes) step() (string, NodeEnum) {
+	if p.delta < 0 {
+		return fmt.Sprintf("PrevSibling(%d)", -p.delta), p.data
+	}
+	return fmt.Sprintf("NextSibling(%d)", p.delta), p.data
+}
+
+func (p *siblingNodes) withInput(input NodeEnum) NodeEnum {
+	return &siblingNodes{input, p.delta}
+}
+
+func (p *prevSiblingNodes) step() (string, NodeEnum) {
+	return "PrevSiblings", p.data
+}
+
+func (p *prevSiblingNodes) withInput(input NodeEnum) NodeEnum {
+	return &prevSiblingNodes{input}
+}
+
+func (p *nextSiblingNodes) step() (string, NodeEnum) {
+	return "NextSiblings", p.data
+}
+
+func (p *nextSiblingNodes) withInput(input NodeEnum) NodeEnum {
+	return &nextSiblingNodes{input}
+}
+
+func (p *firstChildNodes) step() (string, NodeEnum) {
+	return "FirstChild(" + nodeTypeName(p.nodeType) + ")", p.data
+}
+
+func (p *firstChildNodes) withInput(input NodeEnum) NodeEnum {
+	return &firstChildNodes{input, p.nodeType}
+}
+
+func (p *lastChildNodes) step() (string, NodeEnum) {
+	return "LastChild(" + nodeTypeName(p.nodeType) + ")", p.data
+}
+
+func (p *lastChildNodes) withInput(input NodeEnum) NodeEnum {
+	return &lastChildNodes{input, p.nodeType}
+}
+
+func (p *textNodes) step() (string, NodeEnum) {
+	return fmt.Sprintf("ChildrenAsText(%v)", p.doReplace), p.data
+}
+
+func (p *textNodes) withInput(input NodeEnum) NodeEnum {
+	return &textNodes{input, p.doReplace}
+}
+
+func (p *axisNodes) step() (string, NodeEnum) {
+	return p.name, p.data
+}
+
+func (p *axisNodes) withInput(input NodeEnum) NodeEnum {
+	return &axisNodes{input, p.name, p.axis}
+}
+
+// -----------------------------------------------------------------------------
+
+// queryInput is the placeholder input of a chain being compiled.
+type queryInput struct {
+	used bool
+}
+
+func (p *queryInput) ForEach(filter func(node *html.Node) error) {
+	p.used = true
+}
+
+// Query - a compiled chain, which can run on many documents.
+type Query struct {
+	plan NodeEnum
+}
+
+// Compile compiles chain into a query, eg.
+//
+//	q, err := hq.Compile(func(doc hq.NodeSet) hq.NodeSet {
+//		return doc.Any().Div().ContainsClass("item").Child().A()
+//	})
+//
+// chain is called once. It returns ErrNotCompilable if chain visits the node
+// set while building it (eg. calls One or Cache) or has steps which aren't
+// provided by this package.
+func Compile(chain func(doc NodeSet) NodeSet) (q *Query, err error) {
+	input := new(queryInput)
+	ret := chain(NodeSet{Data: input})
+	if input.used {
+		return nil, ErrNotCompilable
+	}
+	if ret.Err != nil {
+		return nil, ret.Err
+	}
+	for data := ret.Data; data != input; {
+		s, ok := data.(planNode)
+		if !ok {
+			return nil, ErrNotCompilable
+		}
+		_, data = s.step()
+	}
+	return &Query{ret.Data}, nil
+}
+
+// Run runs the query on doc.
+func (p *Query) Run(doc NodeSet) (ret NodeSet) {
+	if doc.Err != nil {
+		return doc
+	}
+	return NodeSet{Data: rebind(p.plan, doc.Data)}
+}
+
+// Explain returns the query plan, see NodeSet.Explain.
+func (p *Query) Explain() string {
+	return explain(p.plan)
+}
+
+// rebind returns a copy of the chain plan, whose first input (eg. the
+// queryInput) is replaced by input.
+func rebind(plan NodeEnum, input NodeEnum) NodeEnum {
+	if s, ok := plan.(planNode); ok {
+		_, data := s.step()
+		return s.withInput(rebind(data, input))
+	}
+	return input // the queryInput
+}
+
+// -----------------------------------------------------------------------------
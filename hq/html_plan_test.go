@@ -0,0 +1,203 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// largePage returns a page of n items, and every 10th item has a nested list.
+func largePage(n int) string {
+	var b strings.Builder
+	b.WriteString(`<html><body><div id="main"><ul class="list">`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `<li class="item" id="i%d"><div class="title"><a href="/%d">item %d</a></div>`, i, i, i)
+		if i%10 == 0 {
+			fmt.Fprintf(&b, `<ul><li class="item sub" id="s%d"><a href="/%d/sub">sub</a></li></ul>`, i, i)
+		}
+		b.WriteString(`<p class="desc">some <b>text</b></p></li>`)
+	}
+	b.WriteString(`</ul></div></body></html>`)
+	return b.String()
+}
+
+// unfuse returns a copy of the chain without fused steps: an Any step with
+// predicates becomes an Any step followed by Match steps, and a Match step
+// with many predicates becomes Match steps with one predicate each.
+func unfuse(data NodeEnum) NodeEnum {
+	switch v := data.(type) {
+	case *anyMatchedNodes:
+		data = &anyNodes{unfuse(v.data), v.mode}
+		for _, pred := range v.preds {
+			data = &matchedNodes{data, []predicate{pred}}
+		}
+	case *matchedNodes:
+		data = unfuse(v.data)
+		for _, pred := range v.preds {
+			data = &matchedNodes{data, []predicate{pred}}
+		}
+	case *anyNodes:
+		data = &anyNodes{unfuse(v.data), v.mode}
+	case *childLevelNodes:
+		data = &childLevelNodes{unfuse(v.data), v.level}
+	}
+	return data
+}
+
+var planChains = []struct {
+	name  string
+	chain func(doc NodeSet, mode AnyMode) NodeSet
+}{
+	{"tag", func(doc NodeSet, mode AnyMode) NodeSet {
+		return doc.Any(mode).Li()
+	}},
+	{"tag and class", func(doc NodeSet, mode AnyMode) NodeSet {
+		return doc.Any(mode).Li().ContainsClass("item")
+	}},
+	{"id", func(doc NodeSet, mode AnyMode) NodeSet {
+		return doc.Any(mode).Attribute("id", "s20")
+	}},
+	{"child", func(doc NodeSet, mode AnyMode) NodeSet {
+		return doc.Any(mode).Div().ContainsClass("title").Child().A()
+	}},
+	{"nested any", func(doc NodeSet, mode AnyMode) NodeSet {
+		return doc.Any().Ul().Any(mode).A().HasAttr("href")
+	}},
+	{"not matched", func(doc NodeSet, mode AnyMode) NodeSet {
+		return doc.Any(mode).Li().ContainsClass("none")
+	}},
+}
+
+func TestFusedPlans(t *testing.T) {
+	doc := Source.String(largePage(50))
+	indexed := doc.Index()
+	for _, c := range planChains {
+		for _, mode := range []AnyMode{AnyAll, AnyOutermost, AnyInnermost} {
+			fused := c.chain(doc, mode)
+			if !strings.Contains(fused.Explain(), " where ") {
+				t.Fatalf("%s: not fused:\n%s", c.name, fused.Explain())
+			}
+			want := nodeIDs(t, NodeSet{Data: unfuse(fused.Data)})
+			if got := nodeIDs(t, fused); got != want {
+				t.Errorf("%s (%s): got %q, want %q", c.name, anyDesc(mode), got, want)
+			}
+			if got := nodeIDs(t, c.chain(indexed, mode)); got != want {
+				t.Errorf("%s (%s, indexed): got %q, want %q", c.name, anyDesc(mode), got, want)
+			}
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	q, err := Compile(func(doc NodeSet) NodeSet {
+		return doc.Any().Li().ContainsClass("item").Child().Div().Child().A()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(q.Explain(), "Input\n") {
+		t.Fatal("Explain:", q.Explain())
+	}
+	docs := []NodeSet{Source.String(largePage(3)), Source.String(largePage(12)), Source.String(`<p>none</p>`)}
+	for i, doc := range docs {
+		want := nodeIDs(t, doc.Any().Li().ContainsClass("item").Child().Div().Child().A())
+		if got := nodeIDs(t, q.Run(doc)); got != want {
+			t.Errorf("doc %d: got %q, want %q", i, got, want)
+		}
+		if got := nodeIDs(t, q.Run(doc.Index())); got != want {
+			t.Errorf("doc %d (indexed): got %q, want %q", i, got, want)
+		}
+	}
+	all, err := q.Run(Concat(docs...)).Collect()
+	if err != nil || len(all) != 3+12 {
+		t.Fatal("Concat:", len(all), err)
+	}
+	if err := q.Run(NodeSet{Err: ErrNotFound}).Err; err != ErrNotFound {
+		t.Fatal("Run on error:", err)
+	}
+}
+
+func TestNotCompilable(t *testing.T) {
+	chains := map[string]func(doc NodeSet) NodeSet{
+		"One":    func(doc NodeSet) NodeSet { return doc.Any().Li().One().Child() },
+		"Cache":  func(doc NodeSet) NodeSet { return doc.Any().Li().Cache() },
+		"Concat": func(doc NodeSet) NodeSet { return Concat(doc, doc).Any().Li() },
+		"Nodes":  func(doc NodeSet) NodeSet { return Source.String(`<li>1</li>`).Any().Li() },
+	}
+	for name, chain := range chains {
+		if q, err := Compile(chain); err != ErrNotCompilable || q != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := Compile(func(doc NodeSet) NodeSet { return NodeSet{Err: ErrTooManyNodes} }); err != ErrTooManyNodes {
+		t.Error("chain error:", err)
+	}
+}
+
+func benchmarkChains(b *testing.B, doc NodeSet, run func(chain func(doc NodeSet, mode AnyMode) NodeSet, mode AnyMode) NodeSet) {
+	for _, c := range planChains {
+		for _, mode := range []AnyMode{AnyAll, AnyOutermost, AnyInnermost} {
+			b.Run(c.name+"/"+anyModeNames[mode], func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					run(c.chain, mode).ForEachNode(func(node *html.Node) error {
+						return nil
+					})
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkFused(b *testing.B) {
+	doc := Source.String(largePage(2000))
+	benchmarkChains(b, doc, func(chain func(doc NodeSet, mode AnyMode) NodeSet, mode AnyMode) NodeSet {
+		return chain(doc, mode)
+	})
+}
+
+func BenchmarkUnfused(b *testing.B) {
+	doc := Source.String(largePage(2000))
+	benchmarkChains(b, doc, func(chain func(doc NodeSet, mode AnyMode) NodeSet, mode AnyMode) NodeSet {
+		return NodeSet{Data: unfuse(chain(doc, mode).Data)}
+	})
+}
+
+func BenchmarkIndexed(b *testing.B) {
+	doc := Source.String(largePage(2000)).Index()
+	benchmarkChains(b, doc, func(chain func(doc NodeSet, mode AnyMode) NodeSet, mode AnyMode) NodeSet {
+		return chain(doc, mode)
+	})
+}
+
+func BenchmarkCompiled(b *testing.B) {
+	doc := Source.String(largePage(2000))
+	q, err := Compile(func(doc NodeSet) NodeSet {
+		return doc.Any().Li().ContainsClass("item").Child().Div().Child().A()
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		q.Run(doc).ForEachNode(func(node *html.Node) error {
+			return nil
+		})
+	}
+}
@@ -18,10 +18,13 @@ package hq
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"syscall"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 var (
@@ -48,6 +51,40 @@ type NodeEnum interface {
 	ForEach(filter func(node *html.Node) error)
 }
 
+// failingNodeEnum is implemented by sources which may fail while they are
+// visited, eg. Pages and StreamNodes. Terminal methods of NodeSet (Collect,
+// CollectOne, etc.) return their errors.
+type failingNodeEnum interface {
+	NodeEnum
+	forEachErr(filter func(node *html.Node) error) error
+}
+
+// sourceRun is a failing source visited by one terminal method call, which
+// keeps the error of the visit.
+type sourceRun struct {
+	src failingNodeEnum
+	err error
+}
+
+func (p *sourceRun) ForEach(filter func(node *html.Node) error) {
+	p.err = p.src.forEachErr(filter)
+}
+
+// forEachErr visits data, and returns the error of its source, if the source
+// is a failingNodeEnum. The chain is rebound to a sourceRun, so that the
+// error belongs to this visit only.
+func forEachErr(data NodeEnum, filter func(node *html.Node) error) error {
+	chain, _ := chainOf(data)
+	src, ok := chain[0].(failingNodeEnum)
+	if !ok {
+		data.ForEach(filter)
+		return nil
+	}
+	run := &sourceRun{src: src}
+	rebind(data, run).ForEach(filter)
+	return run.err
+}
+
 // NodeSet - node set
 type NodeSet struct {
 	Data NodeEnum
@@ -82,7 +119,7 @@ func (p NodeSet) Cache() NodeSet {
 // ForEach visits the node set.
 func (p NodeSet) ForEach(filter func(node NodeSet)) {
 	if p.Err == nil {
-		p.Data.ForEach(func(node *html.Node) error {
+		forEachErr(p.Data, func(node *html.Node) error {
 			t := NodeSet{Data: oneNode{node}}
 			filter(t)
 			return nil
@@ -90,6 +127,28 @@ func (p NodeSet) ForEach(filter func(node NodeSet)) {
 	}
 }
 
+// ForEachNode visits nodes of the node set until visit returns an error. It
+// returns the error of visit (nil if it's ErrBreak), or the error of the node
+// set, eg. a page of Paginate fails to be fetched.
+func (p NodeSet) ForEachNode(visit func(node *html.Node) error) (err error) {
+	if p.Err != nil {
+		return p.Err
+	}
+	srcErr := forEachErr(p.Data, func(node *html.Node) error {
+		if err = visit(node); err != nil {
+			return ErrBreak
+		}
+		return nil
+	})
+	if err == ErrBreak {
+		err = nil
+	}
+	if err == nil {
+		err = srcErr
+	}
+	return
+}
+
 // -----------------------------------------------------------------------------
 
 type oneNode struct {
@@ -113,6 +172,48 @@ func NewSource(r io.Reader) (ret NodeSet) {
 	return NodeSet{Data: oneNode{doc}}
 }
 
+// NewFragmentSource parses r as a html fragment in the context of element
+// contextTag ("body" if empty), and treats top-level fragment nodes as a node set.
+// The context is the element which would contain the fragment, eg. "tr" for
+// `<td>1</td>`, since cells are dropped in the context of "body".
+//
+// The top-level nodes don't belong to a document: they have a nil Parent and
+// no siblings. So Remove does nothing on them, and Unwrap, ReplaceWith and
+// Freeze fail with ErrInvalidNode. Query their descendants to mutate them.
+func NewFragmentSource(r io.Reader, contextTag string) (ret NodeSet) {
+	if contextTag == "" {
+		contextTag = "body"
+	}
+	context := &html.Node{
+		Type:     html.ElementNode,
+		DataAtom: atom.Lookup([]byte(contextTag)),
+		Data:     contextTag,
+	}
+	nodes, err := html.ParseFragment(r, context)
+	if err != nil {
+		return NodeSet{Err: err}
+	}
+	return NodeSet{Data: &fixNodes{nodes}}
+}
+
+func newURLSource(r io.Reader, url string) (ret NodeSet) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return NodeSet{Err: err}
+	}
+	setSourceURL(doc, url)
+	return NodeSet{Data: oneNode{doc}}
+}
+
+func newFileSource(r io.Reader, file string) (ret NodeSet) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return NodeSet{Err: err}
+	}
+	setSourceFile(doc, file)
+	return NodeSet{Data: oneNode{doc}}
+}
+
 // -----------------------------------------------------------------------------
 
 type fixNodes struct {
@@ -136,23 +237,212 @@ func Nodes(nodes ...*html.Node) (ret NodeSet) {
 	return NodeSet{Data: &fixNodes{nodes}}
 }
 
+type concatNodes []NodeEnum
+
+func (p concatNodes) ForEach(filter func(node *html.Node) error) {
+	p.forEachErr(filter)
+}
+
+func (p concatNodes) forEachErr(filter func(node *html.Node) error) (err error) {
+	for _, data := range p {
+		broken := false
+		err = forEachErr(data, func(node *html.Node) error {
+			if filter(node) == ErrBreak {
+				broken = true
+				return ErrBreak
+			}
+			return nil
+		})
+		if broken || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Concat creates a node set which visits node sets one by one. Errors of them
+// (eg. a page of Paginate fails) stop the visit, and are returned by terminal
+// methods like Collect.
+func Concat(sets ...NodeSet) (ret NodeSet) {
+	data := make(concatNodes, len(sets))
+	for i, ns := range sets {
+		if ns.Err != nil {
+			return ns
+		}
+		data[i] = ns.Data
+	}
+	return NodeSet{Data: data}
+}
+
 // -----------------------------------------------------------------------------
 
+// AnyMode - how Any visits descendants of a matched node. A node is matched
+// if the following steps of the chain accept it, eg. a div is matched by
+// `Any().Div()`, and a div with class x is matched by `Any().Div().Class("x")`.
+type AnyMode int
+
+const (
+	// AnyAll visits all nodes, so matched nodes nested in a matched node are
+	// yielded too. It is the default mode.
+	AnyAll AnyMode = iota
+	// AnyOutermost doesn't visit descendants of a matched node.
+	AnyOutermost
+	// AnyInnermost yields a matched node only if none of its descendants is
+	// matched.
+	AnyInnermost
+)
+
 type anyNodes struct {
 	data NodeEnum
+	mode AnyMode
 }
 
 func (p *anyNodes) ForEach(filter func(node *html.Node) error) {
-	p.data.ForEach(func(node *html.Node) error {
-		anyForEach(node, filter)
-		return nil
-	})
+	anyInputForEach(p.data, p.mode, filter)
+}
+
+// anyInputForEach visits descendants of input nodes in mode. If there may be
+// many input nodes, they may be nested (eg. divs of `Any().Div().Any().A()`),
+// so anyRun visits them and yields each node once.
+func anyInputForEach(data NodeEnum, mode AnyMode, filter func(node *html.Node) error) {
+	switch data.(type) {
+	case oneNode, *indexedDoc:
+		data.ForEach(func(node *html.Node) error {
+			return anyModeForEach(mode, node, filter)
+		})
+		return
+	}
+	run := &anyRun{mode: mode, filter: filter, done: make(map[*html.Node]bool)}
+	if mode == AnyOutermost {
+		run.matched = make(map[*html.Node]bool)
+	}
+	data.ForEach(run.visit)
+}
+
+// anyModeForEach visits node and its descendants in mode. It returns ErrBreak
+// if filter breaks, so that the input of an Any step stops too.
+func anyModeForEach(mode AnyMode, node *html.Node, filter func(node *html.Node) error) (err error) {
+	switch mode {
+	case AnyOutermost:
+		err = anyForEach(node, filter)
+	case AnyInnermost:
+		_, err = innermostForEach(node, filter)
+	default:
+		err = subtreeForEach(node, filter)
+	}
+	if err != ErrBreak {
+		err = nil
+	}
+	return
 }
 
 func (p *anyNodes) Cached() int {
 	return -1
 }
 
+// anyMatchedNodes is an Any step followed by Match steps, with predicates
+// pushed into the Any step. Unlike anyNodes, it isn't a cachedNodeEnum, so
+// Cache collects it.
+type anyMatchedNodes struct {
+	data  NodeEnum
+	mode  AnyMode
+	preds []predicate
+}
+
+func (p *anyMatchedNodes) ForEach(filter func(node *html.Node) error) {
+	if doc, ok := p.data.(*indexedDoc); ok && p.mode == AnyAll {
+		if nodes, ok := doc.candidates(p.preds); ok {
+			for _, node := range nodes {
+				if matchAll(p.preds, node) && filter(node) == ErrBreak {
+					return
+				}
+			}
+			return
+		}
+	}
+	anyInputForEach(p.data, p.mode, func(node *html.Node) error {
+		if matchAll(p.preds, node) {
+			return filter(node)
+		}
+		return ErrNotFound
+	})
+}
+
+// anyRun visits descendants of many input nodes of an Any step, which yields
+// each node once even if input nodes are nested:
+//   - An input node nested in a visited one is skipped in AnyAll and
+//     AnyInnermost modes, since its descendants are visited already. In
+//     AnyOutermost mode it's visited, but matched nodes aren't yielded again.
+//   - A visited input node nested in the visiting one isn't visited again.
+type anyRun struct {
+	mode    AnyMode
+	filter  func(node *html.Node) error
+	done    map[*html.Node]bool // visited input nodes => if any of their descendants is matched
+	matched map[*html.Node]bool // matched nodes in AnyOutermost mode
+}
+
+func (p *anyRun) visit(node *html.Node) error {
+	nested := false
+	for parent := node; parent != nil; parent = parent.Parent {
+		if _, ok := p.done[parent]; ok {
+			nested = true
+			break
+		}
+	}
+	if nested && p.mode != AnyOutermost {
+		return nil
+	}
+	matched, err := p.walk(node, node)
+	p.done[node] = matched
+	return err
+}
+
+// walk visits node and its descendants like anyModeForEach, but skips visited
+// input nodes other than root.
+func (p *anyRun) walk(root, node *html.Node) (matched bool, err error) {
+	if node != root {
+		if m, ok := p.done[node]; ok {
+			return m, nil
+		}
+	}
+	switch p.mode {
+	case AnyOutermost:
+		if p.matched[node] {
+			return true, nil
+		}
+		switch err = p.filter(node); err {
+		case nil:
+			p.matched[node] = true
+			return true, nil
+		case ErrBreak:
+			return true, err
+		}
+	case AnyAll:
+		if err = p.filter(node); err == ErrBreak {
+			return true, err
+		}
+		matched = err == nil
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		m, err := p.walk(root, child)
+		if err == ErrBreak {
+			return true, err
+		}
+		matched = matched || m
+	}
+	if p.mode == AnyInnermost && !matched {
+		switch err = p.filter(node); err {
+		case nil:
+			return true, nil
+		case ErrBreak:
+			return true, err
+		}
+	}
+	return matched, nil
+}
+
+// anyForEach visits p and its descendants in document order, but doesn't visit
+// descendants of a node if filter returns nil for it.
 func anyForEach(p *html.Node, filter func(node *html.Node) error) error {
 	if err := filter(p); err == nil || err == ErrBreak {
 		return err
@@ -165,12 +455,43 @@ func anyForEach(p *html.Node, filter func(node *html.Node) error) error {
 	return nil
 }
 
-// Any returns deeply visiting node set.
-func (p NodeSet) Any() (ret NodeSet) {
+// innermostForEach visits descendants of p before p, and visits p only if
+// filter doesn't return nil for any of its descendants. Nodes for which filter
+// returns nil are still in document order, since none of them is an ancestor
+// of another.
+func innermostForEach(p *html.Node, filter func(node *html.Node) error) (matched bool, err error) {
+	for node := p.FirstChild; node != nil; node = node.NextSibling {
+		m, err := innermostForEach(node, filter)
+		if err == ErrBreak {
+			return true, err
+		}
+		matched = matched || m
+	}
+	if matched {
+		return true, nil
+	}
+	switch err = filter(p); err {
+	case nil:
+		return true, nil
+	case ErrBreak:
+		return true, err
+	}
+	return false, nil
+}
+
+// Any returns deeply visiting node set, which visits all nodes and their
+// descendants in document order. mode is AnyAll by default. Each node is
+// yielded once, even if nodes of p are nested, eg. `Any().Div().Any().A()`
+// yields an a in nested divs once.
+func (p NodeSet) Any(mode ...AnyMode) (ret NodeSet) {
 	if p.Err != nil {
 		return p
 	}
-	return NodeSet{Data: &anyNodes{p.Data}}
+	m := AnyAll
+	if mode != nil {
+		m = mode[0]
+	}
+	return NodeSet{Data: &anyNodes{p.Data, m}}
 }
 
 // -----------------------------------------------------------------------------
@@ -422,14 +743,44 @@ func (p NodeSet) LastElementChild() (ret NodeSet) {
 
 // -----------------------------------------------------------------------------
 
+// predicate - a node predicate of Match, with a description for query plans.
+type predicate struct {
+	fn   func(node *html.Node) bool
+	name string
+	args []interface{}
+	key  indexKey // used to look up the document index, see NodeSet.Index
+}
+
+func (p predicate) String() string {
+	if p.args == nil {
+		return p.name
+	}
+	args := make([]string, len(p.args))
+	for i, arg := range p.args {
+		args[i] = fmt.Sprintf("%q", arg)
+	}
+	return p.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func matchAll(preds []predicate, node *html.Node) bool {
+	for _, pred := range preds {
+		if !pred.fn(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchedNodes filters nodes by predicates. Consecutive Match steps are fused
+// into one matchedNodes.
 type matchedNodes struct {
-	data   NodeEnum
-	filter func(node *html.Node) bool
+	data  NodeEnum
+	preds []predicate
 }
 
 func (p *matchedNodes) ForEach(filter func(node *html.Node) error) {
 	p.data.ForEach(func(node *html.Node) error {
-		if p.filter(node) {
+		if matchAll(p.preds, node) {
 			return filter(node)
 		}
 		return ErrNotFound
@@ -438,10 +789,37 @@ func (p *matchedNodes) ForEach(filter func(node *html.Node) error) {
 
 // Match filters the node set.
 func (p NodeSet) Match(filter func(node *html.Node) bool) (ret NodeSet) {
+	return p.match(filter, "Match(func)")
+}
+
+// match filters the node set by filter, which is described as name(args...)
+// in query plans. It fuses filter into the previous step if it's a Match or an
+// Any step.
+func (p NodeSet) match(filter func(node *html.Node) bool, name string, args ...interface{}) (ret NodeSet) {
+	return p.matchIndexed(indexKey{}, filter, name, args...)
+}
+
+// matchIndexed is match with a predicate which can be answered by key of the
+// document index.
+func (p NodeSet) matchIndexed(key indexKey, filter func(node *html.Node) bool, name string, args ...interface{}) (ret NodeSet) {
 	if p.Err != nil {
 		return p
 	}
-	return NodeSet{Data: &matchedNodes{p.Data, filter}}
+	pred := predicate{filter, name, args, key}
+	switch data := p.Data.(type) {
+	case *matchedNodes:
+		return NodeSet{Data: &matchedNodes{data.data, appendPredicate(data.preds, pred)}}
+	case *anyNodes:
+		return NodeSet{Data: &anyMatchedNodes{data.data, data.mode, []predicate{pred}}}
+	case *anyMatchedNodes:
+		return NodeSet{Data: &anyMatchedNodes{data.data, data.mode, appendPredicate(data.preds, pred)}}
+	}
+	return NodeSet{Data: &matchedNodes{p.Data, []predicate{pred}}}
+}
+
+// appendPredicate appends pred to a copy of preds, since node sets are shared.
+func appendPredicate(preds []predicate, pred predicate) []predicate {
+	return append(preds[:len(preds):len(preds)], pred)
 }
 
 // -----------------------------------------------------------------------------
@@ -454,23 +832,38 @@ type textNodes struct {
 func (p *textNodes) ForEach(filter func(node *html.Node) error) {
 	p.data.ForEach(func(t *html.Node) error {
 		node := &html.Node{
-			Parent: t,
-			Type:   html.TextNode,
-			Data:   Text(t),
+			Type: html.TextNode,
+			Data: Text(t),
 		}
-		if p.doReplace {
-			t.FirstChild = node
-			t.LastChild = node
+		if p.doReplace && !Frozen(t) { // frozen after ChildrenAsText is called
+			touchDocument(t)
+			removeChildren(t)
+			t.AppendChild(node)
+		} else {
+			node.Parent = t
 		}
 		return filter(node)
 	})
 }
 
-// ChildrenAsText converts all children as text node.
+// ChildrenAsText converts all children as text node. If doReplace is true,
+// children are replaced by the text node, and it fails with ErrFrozen if the
+// document of any node is frozen, like other mutations.
 func (p NodeSet) ChildrenAsText(doReplace bool) (ret NodeSet) {
 	if p.Err != nil {
 		return p
 	}
+	if doReplace {
+		nodes, err := p.Collect()
+		if err != nil {
+			return NodeSet{Err: err}
+		}
+		for _, node := range nodes {
+			if Frozen(node) {
+				return NodeSet{Err: p.nodeError(node, "ChildrenAsText(true)", ErrFrozen)}
+			}
+		}
+	}
 	return NodeSet{Data: &textNodes{p.Data, doReplace}}
 }
 
@@ -478,29 +871,59 @@ func (p NodeSet) ChildrenAsText(doReplace bool) (ret NodeSet) {
 
 // CollectOne collects one node of a node set.
 // If exactly is true, it returns ErrTooManyNodes when node set is more than one.
+// ErrNotFound and ErrTooManyNodes are returned as a *QueryError, which tells
+// where the query fails (see QueryError).
 func (p NodeSet) CollectOne(exactly ...bool) (item *html.Node, err error) {
 	if p.Err != nil {
 		return nil, p.Err
 	}
+	run := newQueryRun(p.Data)
+	item, second, err := collectOne(run.forEach, exactly)
+	if err == ErrNotFound || err == ErrTooManyNodes {
+		err = run.error(err, second)
+	}
+	return
+}
+
+// collectOne is CollectOne without wrapping errors, for callers which test
+// if a node set is empty (eg. Has) and don't need to know why.
+func (p NodeSet) collectOne(exactly ...bool) (item *html.Node, err error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	item, _, err = collectOne(func(filter func(node *html.Node) error) error {
+		return forEachErr(p.Data, filter)
+	}, exactly)
+	return
+}
+
+// collectOne collects one node by forEach, which visits a node set and
+// returns the error of its source. If exactly is true and there are more than
+// one nodes, second is the second node.
+func collectOne(forEach func(filter func(node *html.Node) error) error, exactly []bool) (item, second *html.Node, err error) {
 	err = ErrNotFound
+	var srcErr error
 	if exactly != nil {
 		if !exactly[0] {
 			panic("please call `CollectOne()` instead of `CollectOne(false)`")
 		}
-		p.Data.ForEach(func(node *html.Node) error {
+		srcErr = forEach(func(node *html.Node) error {
 			if err == ErrNotFound {
 				item, err = node, nil
 				return nil
 			}
-			err = ErrTooManyNodes
+			second, err = node, ErrTooManyNodes
 			return ErrBreak
 		})
 	} else {
-		p.Data.ForEach(func(node *html.Node) error {
+		srcErr = forEach(func(node *html.Node) error {
 			item, err = node, nil
 			return ErrBreak
 		})
 	}
+	if srcErr != nil {
+		return nil, nil, srcErr
+	}
 	return
 }
 
@@ -509,7 +932,7 @@ func (p NodeSet) Collect() (items []*html.Node, err error) {
 	if p.Err != nil {
 		return nil, p.Err
 	}
-	p.Data.ForEach(func(node *html.Node) error {
+	err = forEachErr(p.Data, func(node *html.Node) error {
 		items = append(items, node)
 		return nil
 	})
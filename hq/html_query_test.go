@@ -0,0 +1,132 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func nodeIDs(t *testing.T, ns NodeSet) string {
+	nodes, err := ns.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = attrOr(node, "id", node.Data)
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestFragment(t *testing.T) {
+	if nodes, _ := Source.Fragment(`<td>1</td>`, "").Td().Collect(); len(nodes) != 0 {
+		t.Fatal("cells in body:", len(nodes))
+	}
+	cells := Source.Fragment(`<td>1</td><td>2</td>`, "tr")
+	nodes, err := cells.Td().Collect()
+	if err != nil || len(nodes) != 2 {
+		t.Fatal("cells in tr:", nodes, err)
+	}
+	for _, node := range nodes {
+		if node.Parent != nil || node.PrevSibling != nil || node.NextSibling != nil {
+			t.Fatal("top-level node is linked")
+		}
+	}
+	if err := cells.Unwrap().Err; !errors.Is(err, ErrInvalidNode) {
+		t.Fatal("Unwrap:", err)
+	}
+	if err := cells.ReplaceWith("<td>3</td>").Err; !errors.Is(err, ErrInvalidNode) {
+		t.Fatal("ReplaceWith:", err)
+	}
+	if err := cells.Freeze().Err; !errors.Is(err, ErrInvalidNode) {
+		t.Fatal("Freeze:", err)
+	}
+	if text, err := cells.Td().Text(); err != nil || strings.TrimSpace(text) != "1" {
+		t.Fatal("Text:", text, err)
+	}
+}
+
+func TestAnyModes(t *testing.T) {
+	doc := Source.String(`<div id="d1"><div id="d2"><p id="p1"></p><div id="d3"></div></div><p id="p2"></p></div>`)
+	modes := []AnyMode{AnyAll, AnyOutermost, AnyInnermost}
+	cases := []struct {
+		name  string
+		chain func(doc NodeSet, mode AnyMode) NodeSet
+		want  [3]string
+	}{
+		{"div", func(doc NodeSet, mode AnyMode) NodeSet {
+			return doc.Any(mode).Div()
+		}, [3]string{"d1 d2 d3", "d1", "d3"}},
+		{"not matched", func(doc NodeSet, mode AnyMode) NodeSet {
+			return doc.Any(mode).Span()
+		}, [3]string{"", "", ""}},
+		{"nested inputs", func(doc NodeSet, mode AnyMode) NodeSet {
+			return doc.Any().Div().Any(mode).P()
+		}, [3]string{"p1 p2", "p1 p2", "p1 p2"}},
+		{"nested inputs of the same tag", func(doc NodeSet, mode AnyMode) NodeSet {
+			return doc.Any().Div().Any(mode).Div()
+		}, [3]string{"d1 d2 d3", "d1 d2 d3", "d3"}},
+		{"ancestor input after descendant", func(doc NodeSet, mode AnyMode) NodeSet {
+			return doc.Any().P().Parent().Any(mode).P()
+		}, [3]string{"p1 p2", "p1 p2", "p1 p2"}},
+		{"same inputs", func(doc NodeSet, mode AnyMode) NodeSet {
+			return doc.Any().P().Parent().Parent().Any(mode).P()
+		}, [3]string{"p1 p2", "p1 p2", "p1 p2"}},
+	}
+	for _, c := range cases {
+		for i, mode := range modes {
+			if got := nodeIDs(t, c.chain(doc, mode)); got != c.want[i] {
+				t.Errorf("%s (%s): got %q, want %q", c.name, anyDesc(mode), got, c.want[i])
+			}
+		}
+	}
+	if got := nodeIDs(t, Source.String(`<div><div><a id="a"></a></div></div>`).Any().Div().Any().A()); got != "a" {
+		t.Errorf("duplicated: got %q", got)
+	}
+}
+
+func TestAnyCommentThreads(t *testing.T) {
+	doc := Source.String(`<ul>
+<li class="comment"><p>1</p>
+  <ul><li class="comment"><p>1.1</p>
+    <ul><li class="comment"><p>1.1.1</p></li></ul></li>
+  <li class="comment"><p>1.2</p></li></ul></li>
+<li class="comment"><p>2</p></li>
+</ul>`)
+	cases := []struct {
+		mode AnyMode
+		want string
+	}{
+		{AnyAll, "1 1.1 1.1.1 1.2 2"},
+		{AnyOutermost, "1 2"},
+		{AnyInnermost, "1.1.1 1.2 2"},
+	}
+	for _, c := range cases {
+		var texts []string
+		err := doc.Any(c.mode).Li().ContainsClass("comment").Child().P().ForEachNode(func(node *html.Node) error {
+			texts = append(texts, strings.TrimSpace(Text(node)))
+			return nil
+		})
+		if got := strings.Join(texts, " "); err != nil || got != c.want {
+			t.Errorf("%s: got %q, want %q (%v)", anyDesc(c.mode), got, c.want, err)
+		}
+	}
+}
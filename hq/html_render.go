@@ -0,0 +1,364 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// -----------------------------------------------------------------------------
+
+// RenderOptions - options of NodeSet.Render.
+type RenderOptions struct {
+	Inner     bool   // renders children of nodes only
+	Separator string // written between two nodes
+
+	// Indent pretty prints elements which have block elements but no text as
+	// children, putting each child on its own line indented by Indent.
+	Indent string
+
+	CollapseWhitespace bool // collapses whitespace in text, and drops whitespace around block elements
+	DropComments       bool // drops comments
+	OmitOptionalTags   bool // omits optional end tags, like </li>, </td> and </p>
+
+	// Minify is a shortcut of CollapseWhitespace, DropComments and OmitOptionalTags.
+	Minify bool
+}
+
+// Render renders all nodes to w.
+func (p NodeSet) Render(w io.Writer, opts *RenderOptions) error {
+	if p.Err != nil {
+		return p.Err
+	}
+	var o RenderOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Minify {
+		o.CollapseWhitespace, o.DropComments, o.OmitOptionalTags = true, true, true
+	}
+	r := &renderer{w: bufio.NewWriter(w), opts: &o}
+	first := true
+	srcErr := forEachErr(p.Data, func(node *html.Node) error {
+		if !first && o.Separator != "" {
+			r.writeString(o.Separator)
+		}
+		first = false
+		if o.Inner {
+			r.children(node, 0)
+		} else {
+			r.top = node
+			r.node(node, 0)
+		}
+		if r.err != nil {
+			return ErrBreak
+		}
+		return nil
+	})
+	if r.err != nil {
+		return r.err
+	}
+	if srcErr != nil {
+		return srcErr
+	}
+	return r.w.Flush()
+}
+
+// OuterHTML returns html of the node (including itself).
+func (p NodeSet) OuterHTML(exactlyOne ...bool) (text string, err error) {
+	return p.renderOne(false, exactlyOne)
+}
+
+// InnerHTML returns html of the node's children.
+func (p NodeSet) InnerHTML(exactlyOne ...bool) (text string, err error) {
+	return p.renderOne(true, exactlyOne)
+}
+
+func (p NodeSet) renderOne(inner bool, exactlyOne []bool) (text string, err error) {
+	node, err := p.CollectOne(exactlyOne...)
+	if err != nil {
+		return
+	}
+	var b strings.Builder
+	if err = Nodes(node).Render(&b, &RenderOptions{Inner: inner}); err != nil {
+		return
+	}
+	return b.String(), nil
+}
+
+// -----------------------------------------------------------------------------
+
+type renderer struct {
+	w       *bufio.Writer
+	opts    *RenderOptions
+	top     *html.Node // node being rendered by NodeSet.Render
+	started bool       // something is written
+	err     error
+}
+
+func (p *renderer) writeString(s string) {
+	if p.err == nil && s != "" {
+		_, p.err = p.w.WriteString(s)
+		p.started = true
+	}
+}
+
+func (p *renderer) plain() bool {
+	o := p.opts
+	return o.Indent == "" && !o.CollapseWhitespace && !o.DropComments && !o.OmitOptionalTags
+}
+
+// rawElements are rendered by html.Render as a whole, because their content
+// is whitespace sensitive or isn't parsed as html.
+var rawElements = map[string]bool{
+	"script": true, "style": true, "xmp": true, "iframe": true, "noembed": true,
+	"noframes": true, "noscript": true, "plaintext": true, "textarea": true,
+	"title": true, "pre": true, "listing": true,
+}
+
+var blockElements = map[string]bool{
+	"html": true, "head": true, "body": true, "address": true, "article": true,
+	"aside": true, "blockquote": true, "details": true, "dialog": true, "dd": true,
+	"div": true, "dl": true, "dt": true, "fieldset": true, "figcaption": true,
+	"figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hgroup": true,
+	"hr": true, "li": true, "main": true, "nav": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "thead": true, "tbody": true,
+	"tfoot": true, "tr": true, "td": true, "th": true, "caption": true,
+	"colgroup": true, "ul": true, "menu": true, "title": true, "meta": true,
+	"link": true, "script": true, "style": true, "select": true, "option": true,
+	"optgroup": true, "summary": true, "br": true,
+}
+
+func (p *renderer) node(node *html.Node, depth int) {
+	if p.plain() || node.Type != html.ElementNode && node.Type != html.DocumentNode ||
+		node.Type == html.ElementNode && node.Namespace == "" && rawElements[node.Data] {
+		if node.Type == html.CommentNode && p.opts.DropComments {
+			return
+		}
+		if node.Type == html.TextNode && p.opts.CollapseWhitespace && !inRawElement(node) {
+			p.writeString(html.EscapeString(collapseSpaces(node.Data)))
+			return
+		}
+		if p.err == nil {
+			p.err = html.Render(p.w, node)
+			p.started = true
+		}
+		return
+	}
+	if node.Type == html.DocumentNode {
+		p.children(node, depth)
+		return
+	}
+	p.startTag(node)
+	if isVoidElement(node) {
+		return
+	}
+	indented := p.children(node, depth+1)
+	if p.opts.OmitOptionalTags && node != p.top && canOmitEndTag(node, nextRendered(p.opts, node)) {
+		return
+	}
+	if indented {
+		p.writeString("\n" + strings.Repeat(p.opts.Indent, depth))
+	}
+	p.writeString("</" + node.Data + ">")
+}
+
+// children renders children of node at depth, and returns if they are indented or not.
+func (p *renderer) children(node *html.Node, depth int) (indented bool) {
+	if p.plain() {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if p.err == nil {
+				p.err = html.Render(p.w, child)
+			}
+		}
+		return
+	}
+	indent := p.opts.Indent != "" && isBlockContainer(node)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if !rendered(p.opts, child) || indent && child.Type == html.TextNode {
+			continue
+		}
+		if indent {
+			if p.started {
+				p.writeString("\n")
+			}
+			p.writeString(strings.Repeat(p.opts.Indent, depth))
+			indented = true
+		}
+		p.node(child, depth)
+	}
+	return
+}
+
+func (p *renderer) startTag(node *html.Node) {
+	p.writeString("<" + node.Data)
+	for _, attr := range node.Attr {
+		key := attr.Key
+		if attr.Namespace != "" {
+			key = attr.Namespace + ":" + key
+		}
+		p.writeString(" " + key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	if isVoidElement(node) && !p.opts.OmitOptionalTags {
+		p.writeString("/>")
+		return
+	}
+	p.writeString(">")
+}
+
+func isVoidElement(node *html.Node) bool {
+	return node.Namespace == "" && voidElements[node.Data]
+}
+
+func inRawElement(node *html.Node) bool {
+	parent := node.Parent
+	return parent != nil && parent.Type == html.ElementNode && parent.Namespace == "" && rawElements[parent.Data]
+}
+
+// isBlockContainer checks if node has block elements but no text other than
+// whitespace as children, so that its children can be put on their own lines.
+func isBlockContainer(node *html.Node) bool {
+	hasBlock := false
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			if strings.TrimFunc(child.Data, isHTMLSpace) != "" {
+				return false
+			}
+		case html.ElementNode:
+			hasBlock = hasBlock || blockElements[child.Data]
+		}
+	}
+	return hasBlock || node.Type == html.DocumentNode
+}
+
+// rendered checks if node is rendered or dropped.
+func rendered(opts *RenderOptions, node *html.Node) bool {
+	switch node.Type {
+	case html.CommentNode:
+		return !opts.DropComments
+	case html.TextNode:
+		if !opts.CollapseWhitespace || strings.TrimFunc(node.Data, isHTMLSpace) != "" || inRawElement(node) {
+			return true
+		}
+		prev, next := node.PrevSibling, node.NextSibling
+		inBlock := node.Parent == nil || isBlock(node.Parent)
+		if prev == nil && inBlock || next == nil && inBlock {
+			return false
+		}
+		return !(prev != nil && isBlock(prev) || next != nil && isBlock(next))
+	}
+	return true
+}
+
+func isBlock(node *html.Node) bool {
+	return node.Type != html.TextNode && (node.Type != html.ElementNode || blockElements[node.Data])
+}
+
+func nextRendered(opts *RenderOptions, node *html.Node) *html.Node {
+	for node = node.NextSibling; node != nil; node = node.NextSibling {
+		if rendered(opts, node) {
+			return node
+		}
+	}
+	return nil
+}
+
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	space := false
+	for _, c := range s {
+		if isHTMLSpace(c) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(c)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+var pClosers = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"details": true, "div": true, "dl": true, "fieldset": true, "figcaption": true,
+	"figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hgroup": true,
+	"hr": true, "main": true, "menu": true, "nav": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "ul": true,
+}
+
+// canOmitEndTag checks if end tag of node can be omitted when it's followed by next.
+func canOmitEndTag(node, next *html.Node) bool {
+	if node.Namespace != "" {
+		return false
+	}
+	nextTag := ""
+	if next != nil {
+		if next.Type != html.ElementNode {
+			return false
+		}
+		nextTag = next.Data
+	}
+	switch node.Data {
+	case "li":
+		return next == nil || nextTag == "li"
+	case "dt":
+		return nextTag == "dt" || nextTag == "dd"
+	case "dd":
+		return next == nil || nextTag == "dt" || nextTag == "dd"
+	case "option":
+		return next == nil || nextTag == "option" || nextTag == "optgroup"
+	case "tr":
+		return next == nil || nextTag == "tr"
+	case "td", "th":
+		return next == nil || nextTag == "td" || nextTag == "th"
+	case "thead":
+		return nextTag == "tbody" || nextTag == "tfoot"
+	case "tbody":
+		return next == nil || nextTag == "tbody" || nextTag == "tfoot"
+	case "tfoot":
+		return next == nil
+	case "p":
+		if next != nil {
+			return pClosers[nextTag]
+		}
+		switch parent := node.Parent; {
+		case parent == nil || parent.Type != html.ElementNode:
+			return false
+		default:
+			switch parent.Data {
+			case "a", "audio", "del", "ins", "map", "noscript", "video":
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,130 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		name string
+		page string // children of body are rendered
+		opts RenderOptions
+		want string
+	}{
+		{"plain", `<div id="d"><p>a</p><!--c--><p>b  c</p></div>`,
+			RenderOptions{},
+			`<div id="d"><p>a</p><!--c--><p>b  c</p></div>`},
+		{"inner", `<div id="d"><p>a</p><!--c--></div>`,
+			RenderOptions{Inner: true},
+			`<p>a</p><!--c-->`},
+		{"separator", `<p>a</p><p>b</p>c`,
+			RenderOptions{Separator: "\n"},
+			"<p>a</p>\n<p>b</p>\nc"},
+		{"drop comments", `<div><!--c--><p>a<!--c--></p></div>`,
+			RenderOptions{DropComments: true},
+			`<div><p>a</p></div>`},
+		{"collapse whitespace", "<div>\n  <p> a \n b </p>\n  <span>x</span> <span>y</span>\n</div>",
+			RenderOptions{CollapseWhitespace: true},
+			`<div><p> a b </p><span>x</span> <span>y</span></div>`},
+		{"indent", "<div><p>a</p><div><p>b</p>\n</div><span>c</span></div>",
+			RenderOptions{Indent: "  "},
+			"<div>\n  <p>a</p>\n  <div>\n    <p>b</p>\n  </div>\n  <span>c</span>\n</div>"},
+		{"indent text", `<div><p>a</p>b</div>`,
+			RenderOptions{Indent: "  "},
+			`<div><p>a</p>b</div>`},
+		{"omit li and td", `<ul><li>1</li><li>2</li></ul><table><tr><td>1</td><td>2</td></tr></table>`,
+			RenderOptions{OmitOptionalTags: true, Separator: "|"},
+			`<ul><li>1<li>2</ul>|<table><tbody><tr><td>1<td>2</table>`},
+		{"omit p", `<div><p>a</p><div>b</div><p>c</p><span>d</span><p>e</p></div>`,
+			RenderOptions{OmitOptionalTags: true},
+			`<div><p>a<div>b</div><p>c</p><span>d</span><p>e</div>`},
+		{"keep p in a", `<a><p>a</p></a>`,
+			RenderOptions{OmitOptionalTags: true},
+			`<a><p>a</p></a>`},
+		{"void", `<p>a<br>b</p>`,
+			RenderOptions{OmitOptionalTags: true},
+			`<p>a<br>b</p>`},
+		{"minify", "<ul>\n <li> 1 </li>\n <!-- c --><li>2</li>\n</ul>",
+			RenderOptions{Minify: true},
+			`<ul><li> 1 <li>2</ul>`},
+		{"pre and textarea", "<div><pre>  a\n   b</pre><textarea>  x  </textarea></div>",
+			RenderOptions{Indent: "  ", Minify: true},
+			"<div>\n  <pre>  a\n   b</pre>\n  <textarea>  x  </textarea>\n</div>"},
+	}
+	for _, c := range cases {
+		var b strings.Builder
+		opts := c.opts
+		err := Source.String(c.page).Any().Body().Child().Render(&b, &opts)
+		if err != nil || b.String() != c.want {
+			t.Errorf("%s:\ngot  %q (%v)\nwant %q", c.name, b.String(), err, c.want)
+		}
+	}
+}
+
+func TestOuterInnerHTML(t *testing.T) {
+	doc := Source.String(`<div><p class="a">x<br>y</p></div>`)
+	if text, err := doc.Any().P().OuterHTML(); err != nil || text != `<p class="a">x<br/>y</p>` {
+		t.Fatal("OuterHTML:", text, err)
+	}
+	if text, err := doc.Any().P().InnerHTML(); err != nil || text != `x<br/>y` {
+		t.Fatal("InnerHTML:", text, err)
+	}
+	if _, err := doc.Any().Span().OuterHTML(); !errors.Is(err, ErrNotFound) {
+		t.Fatal("not found:", err)
+	}
+	if _, err := Source.String(`<p>a</p><p>b</p>`).Any().P().InnerHTML(true); !errors.Is(err, ErrTooManyNodes) {
+		t.Fatal("too many:", err)
+	}
+}
+
+// errWriter fails after n bytes are written.
+type errWriter struct {
+	n int
+}
+
+var errWrite = errors.New("write error")
+
+func (p *errWriter) Write(b []byte) (int, error) {
+	if len(b) > p.n {
+		n := p.n
+		p.n = 0
+		return n, errWrite
+	}
+	p.n -= len(b)
+	return len(b), nil
+}
+
+func TestRenderWriteError(t *testing.T) {
+	doc := Source.String(largePage(200))
+	for _, opts := range []*RenderOptions{nil, {Minify: true, Indent: " "}} {
+		for _, n := range []int{0, 10, 5000} {
+			if err := doc.Render(&errWriter{n}, opts); err != errWrite {
+				t.Errorf("%d bytes: %v", n, err)
+			}
+		}
+		if err := Source.String(`<p>a</p>`).Render(&errWriter{3}, opts); err != errWrite {
+			t.Error("error of Flush:", err)
+		}
+	}
+	if err := (NodeSet{Err: ErrNotFound}).Render(&errWriter{}, nil); err != ErrNotFound {
+		t.Error("NodeSet error:", err)
+	}
+}
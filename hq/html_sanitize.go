@@ -0,0 +1,307 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// -----------------------------------------------------------------------------
+
+// Sanitizer - an allowlist-based html sanitizer policy.
+//
+// Elements not allowed are replaced with their (sanitized) children, except
+// elements like script and style which are removed with their content.
+// Attributes not allowed are removed. Comments and doctypes are removed.
+type Sanitizer struct {
+	elements     map[string]map[string]bool // allowed elements -> allowed attributes
+	globalAttrs  map[string]bool
+	urlAttrs     map[string]bool
+	urlSchemes   map[string]bool
+	styles       map[string]bool
+	stripContent map[string]bool
+
+	// AllowRelativeURLs allows urls without scheme.
+	AllowRelativeURLs bool
+	// RequireNoFollow adds `rel="nofollow"` to links with href.
+	RequireNoFollow bool
+}
+
+// NewSanitizer creates an empty sanitizer policy, which allows nothing but text.
+func NewSanitizer() *Sanitizer {
+	return &Sanitizer{
+		elements:    make(map[string]map[string]bool),
+		globalAttrs: make(map[string]bool),
+		urlAttrs: map[string]bool{
+			"href": true, "src": true, "cite": true, "action": true, "formaction": true,
+			"poster": true, "background": true, "longdesc": true, "usemap": true,
+			"srcset": true, "data": true, "codebase": true, "classid": true,
+			"xlink:href": true, "manifest": true, "icon": true, "profile": true,
+		},
+		urlSchemes: make(map[string]bool),
+		styles:     make(map[string]bool),
+		stripContent: map[string]bool{
+			"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+			"object": true, "embed": true, "applet": true, "noscript": true, "noembed": true,
+			"noframes": true, "template": true, "xmp": true, "plaintext": true, "title": true,
+			"textarea": true, "select": true, "svg": true, "math": true, "head": true,
+			"base": true, "link": true, "meta": true,
+		},
+	}
+}
+
+// AllowElements allows elements (without attributes).
+func (p *Sanitizer) AllowElements(tags ...string) *Sanitizer {
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if p.elements[tag] == nil {
+			p.elements[tag] = make(map[string]bool)
+		}
+	}
+	return p
+}
+
+// AllowAttrs allows attributes of element tag. If tag is "", attributes are
+// allowed for all allowed elements.
+func (p *Sanitizer) AllowAttrs(tag string, attrs ...string) *Sanitizer {
+	allowed := p.globalAttrs
+	if tag != "" {
+		p.AllowElements(tag)
+		allowed = p.elements[strings.ToLower(tag)]
+	}
+	for _, attr := range attrs {
+		allowed[strings.ToLower(attr)] = true
+	}
+	return p
+}
+
+// AllowURLSchemes allows url schemes (eg. "https", "mailto") for url attributes like href and src.
+func (p *Sanitizer) AllowURLSchemes(schemes ...string) *Sanitizer {
+	for _, scheme := range schemes {
+		p.urlSchemes[strings.ToLower(scheme)] = true
+	}
+	return p
+}
+
+// AllowStyles allows css properties in style attributes. Style attributes
+// have to be allowed by AllowAttrs too.
+func (p *Sanitizer) AllowStyles(props ...string) *Sanitizer {
+	for _, prop := range props {
+		p.styles[strings.ToLower(prop)] = true
+	}
+	return p
+}
+
+// UGCPolicy returns a sanitizer policy for user generated content: common
+// text formatting, lists, tables, links and images, with http, https and
+// mailto urls, and `rel="nofollow"` for links.
+func UGCPolicy() *Sanitizer {
+	p := NewSanitizer()
+	p.AllowElements(
+		"a", "abbr", "acronym", "b", "bdi", "bdo", "blockquote", "br", "caption",
+		"cite", "code", "col", "colgroup", "dd", "del", "details", "dfn", "div",
+		"dl", "dt", "em", "figcaption", "figure", "h1", "h2", "h3", "h4", "h5",
+		"h6", "hr", "i", "img", "ins", "kbd", "li", "mark", "ol", "p", "pre", "q",
+		"rp", "rt", "ruby", "s", "samp", "small", "span", "strike", "strong",
+		"sub", "summary", "sup", "table", "tbody", "td", "tfoot", "th", "thead",
+		"time", "tr", "tt", "u", "ul", "var", "wbr")
+	p.AllowAttrs("", "title", "dir", "lang")
+	p.AllowAttrs("a", "href", "name")
+	p.AllowAttrs("img", "src", "alt", "width", "height")
+	p.AllowAttrs("td", "colspan", "rowspan", "headers")
+	p.AllowAttrs("th", "colspan", "rowspan", "headers", "scope")
+	p.AllowAttrs("ol", "start", "reversed")
+	p.AllowAttrs("li", "value")
+	p.AllowAttrs("time", "datetime")
+	p.AllowAttrs("details", "open")
+	for _, tag := range []string{"blockquote", "q", "del", "ins"} {
+		p.AllowAttrs(tag, "cite")
+	}
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs = true
+	p.RequireNoFollow = true
+	return p
+}
+
+// -----------------------------------------------------------------------------
+
+// SanitizeNode sanitizes children of node in place. The node itself is kept.
+func (p *Sanitizer) SanitizeNode(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch child.Type {
+		case html.TextNode:
+		case html.ElementNode:
+			p.sanitizeElement(child)
+		default:
+			node.RemoveChild(child)
+		}
+		child = next
+	}
+}
+
+func (p *Sanitizer) sanitizeElement(node *html.Node) {
+	parent := node.Parent
+	tag := node.Data
+	allowed, ok := p.elements[tag]
+	if node.Namespace != "" {
+		ok = false
+	}
+	if !ok {
+		if p.stripContent[tag] || node.Namespace != "" {
+			parent.RemoveChild(node)
+			return
+		}
+		p.SanitizeNode(node)
+		for child := node.FirstChild; child != nil; child = node.FirstChild {
+			node.RemoveChild(child)
+			parent.InsertBefore(child, node)
+		}
+		parent.RemoveChild(node)
+		return
+	}
+	n := 0
+	for _, attr := range node.Attr {
+		if attr.Namespace != "" || !(allowed[attr.Key] || p.globalAttrs[attr.Key]) {
+			continue
+		}
+		switch {
+		case attr.Key == "style":
+			if attr.Val = p.sanitizeStyle(attr.Val); attr.Val == "" {
+				continue
+			}
+		case attr.Key == "srcset":
+			if !p.allowSrcset(attr.Val) {
+				continue
+			}
+		case p.urlAttrs[attr.Key]:
+			if !p.allowURL(attr.Val) {
+				continue
+			}
+		}
+		node.Attr[n] = attr
+		n++
+	}
+	node.Attr = node.Attr[:n]
+	if p.RequireNoFollow && node.DataAtom == atom.A && hasAttr(node, "href") {
+		rel, _ := AttributeVal(node, "rel")
+		if !ContainsClass(strings.ToLower(rel), "nofollow") {
+			setAttr(node, "rel", strings.TrimSpace(rel+" nofollow"))
+		}
+	}
+	p.SanitizeNode(node)
+}
+
+// allowURL checks if a url attribute value is allowed or not.
+func (p *Sanitizer) allowURL(v string) bool {
+	// browsers ignore tabs and newlines in urls, and leading/trailing spaces or controls.
+	v = strings.Map(func(c rune) rune {
+		if c == '\t' || c == '\n' || c == '\r' {
+			return -1
+		}
+		return c
+	}, v)
+	v = strings.TrimFunc(v, func(c rune) bool {
+		return c <= ' ' || c == 0x7f
+	})
+	end := strings.IndexAny(v, ":/?#")
+	if end < 0 || v[end] != ':' {
+		return p.AllowRelativeURLs
+	}
+	scheme := strings.ToLower(v[:end])
+	for i, c := range scheme {
+		if !(c >= 'a' && c <= 'z' || i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.')) {
+			return false
+		}
+	}
+	return p.urlSchemes[scheme]
+}
+
+// allowSrcset checks if all urls of a srcset value (comma separated image
+// candidates like `a.png 1x, b.png 2x`) are allowed.
+func (p *Sanitizer) allowSrcset(v string) bool {
+	for _, candidate := range strings.Split(v, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 || !p.allowURL(fields[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+var dangerousStyleValues = []string{
+	"url(", "expression", "javascript:", "vbscript:", "\\", "@import", "<",
+	"behavior", "-moz-binding", "/*",
+}
+
+// sanitizeStyle keeps declarations of allowed css properties with safe values.
+func (p *Sanitizer) sanitizeStyle(style string) string {
+	var decls []string
+next:
+	for _, decl := range strings.Split(style, ";") {
+		pos := strings.IndexByte(decl, ':')
+		if pos < 0 {
+			continue
+		}
+		prop := strings.ToLower(strings.TrimSpace(decl[:pos]))
+		val := strings.TrimSpace(decl[pos+1:])
+		if !p.styles[prop] || val == "" {
+			continue
+		}
+		lval := strings.ToLower(val)
+		for _, bad := range dangerousStyleValues {
+			if strings.Contains(lval, bad) {
+				continue next
+			}
+		}
+		decls = append(decls, prop+": "+val)
+	}
+	return strings.Join(decls, "; ")
+}
+
+// Sanitize parses text as a html fragment, sanitizes it, and renders the result.
+func (p *Sanitizer) Sanitize(text string) (string, error) {
+	body := &html.Node{Type: html.ElementNode, DataAtom: atom.Body, Data: "body"}
+	nodes, err := html.ParseFragment(strings.NewReader(text), body)
+	if err != nil {
+		return "", err
+	}
+	for _, node := range nodes {
+		body.AppendChild(node)
+	}
+	p.SanitizeNode(body)
+	var b strings.Builder
+	for child := body.FirstChild; child != nil; child = child.NextSibling {
+		if err = html.Render(&b, child); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// Sanitize sanitizes children of all nodes in place by policy s.
+func (p NodeSet) Sanitize(s *Sanitizer) (ret NodeSet) {
+	return p.mutate("Sanitize", nil, func(node *html.Node) error {
+		s.SanitizeNode(node)
+		return nil
+	})
+}
+
+// -----------------------------------------------------------------------------
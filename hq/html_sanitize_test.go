@@ -0,0 +1,109 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	policy := UGCPolicy().
+		AllowAttrs("img", "srcset").
+		AllowAttrs("a", "rel").
+		AllowAttrs("span", "style").
+		AllowStyles("color", "background")
+	cases := []struct {
+		name, in, want string
+	}{
+		// javascript urls
+		{"js", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"js case", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"js entity", `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"js hex entity", `<a href="&#x6A;avascript&#x3A;alert(1)">x</a>`, `<a>x</a>`},
+		{"js tab", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"js tab entity", `<a href="jav&#x09;ascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"js newline entity", `<a href="jav&#10;ascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"js leading space", `<a href=" &#0;javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"vbscript", `<a href="vbscript:msgbox(1)">x</a>`, `<a>x</a>`},
+		{"data", `<img src="data:image/svg+xml;base64,PHN2Zz4=">`, `<img/>`},
+		{"https", `<a href="https://example.com/">x</a>`, `<a href="https://example.com/" rel="nofollow">x</a>`},
+		{"relative", `<a href="/a?b#c">x</a>`, `<a href="/a?b#c" rel="nofollow">x</a>`},
+		{"colon in path", `<a href="/a:b">x</a>`, `<a href="/a:b" rel="nofollow">x</a>`},
+
+		// event handlers
+		{"onclick", `<a href="/" onclick="alert(1)">x</a>`, `<a href="/" rel="nofollow">x</a>`},
+		{"onerror", `<img src="x" onerror="alert(1)">`, `<img src="x"/>`},
+		{"onload", `<p onload="alert(1)" title="t">x</p>`, `<p title="t">x</p>`},
+
+		// removed with content
+		{"script", `<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`},
+		{"style element", `<style>body{background:url(javascript:x)}</style><p>a</p>`, `<p>a</p>`},
+		{"noscript", `<noscript><img src="x" onerror="alert(1)"></noscript>a`, `a`},
+		{"iframe", `<iframe src="https://example.com/"></iframe>a`, `a`},
+		{"svg", `<svg><script>alert(1)</script><a xlink:href="javascript:x">x</a></svg>a`, `a`},
+		{"svg onload", `<svg onload="alert(1)"/>a`, `a`},
+		{"math", `<math><mi xlink:href="javascript:alert(1)">x</mi></math>a`, `a`},
+		{"comment", `a<!-- <script>alert(1)</script> -->b`, `ab`},
+
+		// style attributes
+		{"style", `<span style="color: red; position: fixed">x</span>`, `<span style="color: red">x</span>`},
+		{"style expression", `<span style="color: expression(alert(1))">x</span>`, `<span>x</span>`},
+		{"style url", `<span style="background: url(javascript:alert(1))">x</span>`, `<span>x</span>`},
+		{"style url upper", `<span style="background: URL(https://example.com/)">x</span>`, `<span>x</span>`},
+		{"style escape", `<span style="background: \75 rl(x)">x</span>`, `<span>x</span>`},
+		{"style comment", `<span style="color: red/**/;background: ur/**/l(x)">x</span>`, `<span>x</span>`},
+
+		// srcset
+		{"srcset", `<img srcset="a.png 1x, https://example.com/b.png 2x">`, `<img srcset="a.png 1x, https://example.com/b.png 2x"/>`},
+		{"srcset js", `<img srcset="a.png 1x, javascript:alert(1) 2x">`, `<img/>`},
+		{"srcset empty candidate", `<img srcset="a.png 1x,,">`, `<img/>`},
+
+		// unwrapping
+		{"unwrap", `<font color="red">a<b>b</b></font>`, `a<b>b</b>`},
+		{"nested unwrap", `<center><font><b>a</b><font>b<script>x</script></font></font></center>c`, `<b>a</b>bc`},
+		{"unwrap form", `<form action="/x"><input name="q"><b>a</b></form>`, `<b>a</b>`},
+
+		// rel
+		{"rel merge", `<a href="/" rel="noopener">x</a>`, `<a href="/" rel="noopener nofollow">x</a>`},
+		{"rel kept", `<a href="/" rel="nofollow noopener">x</a>`, `<a href="/" rel="nofollow noopener">x</a>`},
+		{"rel case", `<a href="/" rel="NoFollow">x</a>`, `<a href="/" rel="NoFollow">x</a>`},
+		{"rel without href", `<a name="top">x</a>`, `<a name="top">x</a>`},
+	}
+	for _, c := range cases {
+		got, err := policy.Sanitize(c.in)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: Sanitize(%q) = %q, want %q", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestSanitizeNodeSet(t *testing.T) {
+	doc := Source.String(`<div id="c"><p onclick="x">a<script>b</script></p></div><p>keep <i>i</i></p>`)
+	if err := doc.Any().ID("c").Sanitize(NewSanitizer().AllowElements("p")).Err; err != nil {
+		t.Fatal(err)
+	}
+	html, err := doc.Any().Body().InnerHTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if html != `<div id="c"><p>a</p></div><p>keep <i>i</i></p>` {
+		t.Fatal("Sanitize:", html)
+	}
+}
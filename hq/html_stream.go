@@ -0,0 +1,316 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrStreamConsumed - stream source is already consumed
+	ErrStreamConsumed = errors.New("stream source is already consumed")
+	// ErrTooManyStreamSteps - too many steps of a stream query
+	ErrTooManyStreamSteps = errors.New("too many steps of a stream query")
+)
+
+// -----------------------------------------------------------------------------
+
+type streamStep struct {
+	descendant bool
+	tag        string // "" matches any element
+	attrs      []html.Attribute
+	classes    []string
+}
+
+func (p *streamStep) match(tok *html.Token) bool {
+	if p.tag != "" && p.tag != tok.Data {
+		return false
+	}
+	for _, want := range p.attrs {
+		if !tokenHasAttr(tok, want.Key, want.Val) {
+			return false
+		}
+	}
+	for _, class := range p.classes {
+		v, ok := tokenAttr(tok, "class")
+		if !ok || !ContainsClass(v, class) {
+			return false
+		}
+	}
+	return true
+}
+
+func tokenAttr(tok *html.Token, k string) (string, bool) {
+	for _, attr := range tok.Attr {
+		if attr.Key == k {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func tokenHasAttr(tok *html.Token, k, v string) bool {
+	val, ok := tokenAttr(tok, k)
+	return ok && val == v
+}
+
+// StreamQuery - a query evaluated in a single pass by a stream source. It
+// supports a restricted subset of node set queries: element, attribute and
+// class matching, with descendant and child steps.
+//
+// For example, the stream query of `doc.Any(hq.AnyOutermost).Div().ContainsClass("item").Child().A()` is
+//
+//	hq.NewStreamQuery().Any().Element("div").ContainsClass("item").Child().Element("a")
+type StreamQuery struct {
+	steps      []streamStep
+	descendant bool
+}
+
+// NewStreamQuery creates a stream query.
+func NewStreamQuery() *StreamQuery {
+	return &StreamQuery{}
+}
+
+// Any makes the next step to match descendants.
+func (p *StreamQuery) Any() *StreamQuery {
+	p.descendant = true
+	return p
+}
+
+// Child makes the next step to match children (it's the default).
+func (p *StreamQuery) Child() *StreamQuery {
+	p.descendant = false
+	return p
+}
+
+// Element adds a step matching elements whose tag is tag ("" for any element).
+func (p *StreamQuery) Element(tag string) *StreamQuery {
+	p.steps = append(p.steps, streamStep{descendant: p.descendant, tag: tag})
+	p.descendant = false
+	return p
+}
+
+func (p *StreamQuery) last() *streamStep {
+	if len(p.steps) == 0 {
+		p.Element("")
+	}
+	return &p.steps[len(p.steps)-1]
+}
+
+// Attribute requires the last step's attribute k's value to be v.
+func (p *StreamQuery) Attribute(k, v string) *StreamQuery {
+	step := p.last()
+	step.attrs = append(step.attrs, html.Attribute{Key: k, Val: v})
+	return p
+}
+
+// ContainsClass requires the last step's attribute `class` to contain v.
+func (p *StreamQuery) ContainsClass(v string) *StreamQuery {
+	step := p.last()
+	step.classes = append(step.classes, v)
+	return p
+}
+
+// ID requires the last step's attribute `id` to be v.
+func (p *StreamQuery) ID(v string) *StreamQuery {
+	return p.Attribute("id", v)
+}
+
+// -----------------------------------------------------------------------------
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+var closesP = []string{"p"}
+
+// impliedEnds[tag] lists elements which are implicitly closed by a start tag.
+var impliedEnds = map[string][]string{
+	"li": {"li"}, "option": {"option"}, "dt": {"dt", "dd"}, "dd": {"dt", "dd"},
+	"tr": {"tr", "td", "th"}, "td": {"td", "th"}, "th": {"td", "th"},
+	"p": closesP, "div": closesP, "ul": closesP, "ol": closesP, "dl": closesP,
+	"table": closesP, "pre": closesP, "form": closesP, "blockquote": closesP,
+	"section": closesP, "article": closesP, "header": closesP, "footer": closesP,
+	"h1": closesP, "h2": closesP, "h3": closesP, "h4": closesP, "h5": closesP, "h6": closesP,
+}
+
+// impliedEnd checks if an open element tag is implicitly closed by the start
+// tag start. Eg. `<tr>` closes an open `td`, and then the `tr` containing it.
+func impliedEnd(start, tag string) bool {
+	for _, end := range impliedEnds[start] {
+		if end == tag {
+			return true
+		}
+	}
+	return false
+}
+
+type streamFrame struct {
+	tag       string
+	matched   uint64 // steps matched by this element
+	reachable uint64 // steps matched by this element or its ancestors
+	node      *html.Node
+}
+
+// StreamNodes - a NodeEnum of a stream source.
+type StreamNodes struct {
+	r     io.Reader
+	steps []streamStep
+	used  int32 // set by the first visit
+}
+
+// NewStreamSource creates a stream source, which tokenizes r in a single pass
+// instead of building the whole document, and yields subtrees of elements
+// matched by q. Only the current open elements and the matched subtree being
+// built are kept in memory. The tree construction is simplified: end tags
+// close the nearest open element with the same tag, and only common implied
+// end tags (li, p, td, etc.) are handled.
+//
+// Nested matches inside a matched element aren't yielded, which is the same
+// as `Any(AnyOutermost)`. A stream source can be visited only once, so call `Cache()` if
+// it needs to be visited multiple times. Visiting it again fails with
+// ErrStreamConsumed. Like errors of reading r, it is returned by terminal
+// methods of NodeSet (Collect, CollectOne, Text, etc.).
+func NewStreamSource(r io.Reader, q *StreamQuery) (ret NodeSet) {
+	if len(q.steps) > 64 {
+		return NodeSet{Err: ErrTooManyStreamSteps}
+	}
+	return NodeSet{Data: &StreamNodes{r: r, steps: q.steps}}
+}
+
+// ForEach visits the matched subtrees. Use NodeSet methods such as Collect to
+// get errors of the visit.
+func (p *StreamNodes) ForEach(filter func(node *html.Node) error) {
+	p.forEachErr(filter)
+}
+
+func (p *StreamNodes) forEachErr(filter func(node *html.Node) error) error {
+	if !atomic.CompareAndSwapInt32(&p.used, 0, 1) {
+		return ErrStreamConsumed
+	}
+	if len(p.steps) == 0 {
+		return nil
+	}
+	final := uint64(1) << uint(len(p.steps)-1)
+	z := html.NewTokenizer(p.r)
+	stack := []streamFrame{{}}
+	capture := 0 // index of the frame being captured in stack, 0 if not capturing
+	pop := func() error {
+		n := len(stack) - 1
+		node := stack[n].node
+		stack = stack[:n]
+		if n == capture {
+			capture = 0
+			return filter(node)
+		}
+		return nil
+	}
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return err
+			}
+			if capture > 0 {
+				filter(stack[capture].node)
+			}
+			return nil
+		case html.TextToken, html.CommentToken:
+			if capture > 0 {
+				tok := z.Token()
+				nodeType := html.TextNode
+				if tok.Type == html.CommentToken {
+					nodeType = html.CommentNode
+				}
+				stack[len(stack)-1].node.AppendChild(&html.Node{Type: nodeType, Data: tok.Data})
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			for len(stack) > 1 && impliedEnd(tok.Data, stack[len(stack)-1].tag) {
+				if pop() == ErrBreak {
+					return nil
+				}
+			}
+			parent := &stack[len(stack)-1]
+			frame := streamFrame{tag: tok.Data}
+			if capture > 0 {
+				frame.node = newStreamNode(&tok)
+				parent.node.AppendChild(frame.node)
+			} else {
+				for i := range p.steps {
+					step := &p.steps[i]
+					var ok bool
+					switch {
+					case i == 0:
+						ok = step.descendant || len(stack) == 1
+					case step.descendant:
+						ok = parent.reachable&(1<<uint(i-1)) != 0
+					default:
+						ok = parent.matched&(1<<uint(i-1)) != 0
+					}
+					if ok && step.match(&tok) {
+						frame.matched |= 1 << uint(i)
+					}
+				}
+				frame.reachable = parent.reachable | frame.matched
+				if frame.matched&final != 0 {
+					frame.node = newStreamNode(&tok)
+					capture = len(stack)
+				}
+			}
+			if tok.Type == html.SelfClosingTagToken || voidElements[tok.Data] {
+				if capture == len(stack) {
+					capture = 0
+					if filter(frame.node) == ErrBreak {
+						return nil
+					}
+				}
+				continue
+			}
+			stack = append(stack, frame)
+		case html.EndTagToken:
+			tok := z.Token()
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].tag == tok.Data {
+					for len(stack) > i {
+						if pop() == ErrBreak {
+							return nil
+						}
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+func newStreamNode(tok *html.Token) *html.Node {
+	return &html.Node{
+		Type:     html.ElementNode,
+		DataAtom: tok.DataAtom,
+		Data:     tok.Data,
+		Attr:     tok.Attr,
+	}
+}
+
+// -----------------------------------------------------------------------------
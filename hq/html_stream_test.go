@@ -0,0 +1,159 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/net/html"
+)
+
+func renderNodes(t *testing.T, nodes []*html.Node) []string {
+	ret := make([]string, len(nodes))
+	for i, node := range nodes {
+		var b strings.Builder
+		if err := html.Render(&b, node); err != nil {
+			t.Fatal(err)
+		}
+		ret[i] = b.String()
+	}
+	return ret
+}
+
+func TestStreamSteps(t *testing.T) {
+	const page = `<div class="item x"><a href="1">1</a><p><a href="2">2</a></p></div>
+<div><a href="3">3</a></div><section><div class="item"><b><a href="4">4</a></b></div></section>`
+	cases := []struct {
+		name string
+		q    *StreamQuery
+		want []string
+	}{
+		{"child", NewStreamQuery().Any().Element("div").ContainsClass("item").Child().Element("a"),
+			[]string{`<a href="1">1</a>`}},
+		{"descendant", NewStreamQuery().Any().Element("div").ContainsClass("item").Any().Element("a"),
+			[]string{`<a href="1">1</a>`, `<a href="2">2</a>`, `<a href="4">4</a>`}},
+		{"root child", NewStreamQuery().Element("section").Any().Element("a"),
+			[]string{`<a href="4">4</a>`}},
+		{"top level", NewStreamQuery().Element("div").Child().Element("a"),
+			[]string{`<a href="1">1</a>`, `<a href="3">3</a>`}},
+		{"not top level", NewStreamQuery().Element("a"), nil},
+		{"three steps", NewStreamQuery().Any().Element("section").Any().Element("b").Child().Element("a"),
+			[]string{`<a href="4">4</a>`}},
+		{"attribute", NewStreamQuery().Any().Element("a").Attribute("href", "3"),
+			[]string{`<a href="3">3</a>`}},
+		{"outermost", NewStreamQuery().Any().Element("div"),
+			[]string{
+				`<div class="item x"><a href="1">1</a><p><a href="2">2</a></p></div>`,
+				`<div><a href="3">3</a></div>`,
+				`<div class="item"><b><a href="4">4</a></b></div>`,
+			}},
+	}
+	for _, c := range cases {
+		nodes, err := NewStreamSource(strings.NewReader(page), c.q).Collect()
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		got := renderNodes(t, nodes)
+		if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestStreamImpliedEnds(t *testing.T) {
+	cases := []struct {
+		page string
+		q    *StreamQuery
+		want []string
+	}{
+		{`<ul><li>a<li>b<li>c</ul>`, NewStreamQuery().Any().Element("li"),
+			[]string{`<li>a</li>`, `<li>b</li>`, `<li>c</li>`}},
+		{`<p>one<p>two<div>three</div>`, NewStreamQuery().Any().Element("p"),
+			[]string{`<p>one</p>`, `<p>two</p>`}},
+		{`<table><tr><td>1<td>2<tr><th>3</table>`, NewStreamQuery().Any().Element("tr"),
+			[]string{`<tr><td>1</td><td>2</td></tr>`, `<tr><th>3</th></tr>`}},
+		{`<dl><dt>k<dd>v<dt>k2</dl>`, NewStreamQuery().Any().Element("dd"),
+			[]string{`<dd>v</dd>`}},
+		{`<div><img src="x"><br>text</div>`, NewStreamQuery().Any().Element("div"),
+			[]string{`<div><img src="x"/><br/>text</div>`}},
+		{`<div>unclosed`, NewStreamQuery().Any().Element("div"),
+			[]string{`<div>unclosed</div>`}},
+	}
+	for _, c := range cases {
+		nodes, err := NewStreamSource(strings.NewReader(c.page), c.q).Collect()
+		if err != nil {
+			t.Fatal(c.page, err)
+		}
+		got := renderNodes(t, nodes)
+		if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Errorf("%s: got %q, want %q", c.page, got, c.want)
+		}
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	doc := NewStreamSource(strings.NewReader(`<a>1</a><a>2</a>`), NewStreamQuery().Any().Element("a"))
+	if text, err := doc.Text(); err != nil || text != "1" {
+		t.Fatal("first visit:", text, err)
+	}
+	if nodes, err := doc.Collect(); err != ErrStreamConsumed || nodes != nil {
+		t.Fatal("second visit:", nodes, err)
+	}
+
+	errRead := errors.New("read error")
+	r := io.MultiReader(strings.NewReader(`<a>1</a>`), iotest.ErrReader(errRead))
+	nodes, err := NewStreamSource(r, NewStreamQuery().Any().Element("a")).Collect()
+	if err != errRead || len(nodes) != 1 {
+		t.Fatal("read error:", nodes, err)
+	}
+
+	steps := NewStreamQuery()
+	for i := 0; i < 65; i++ {
+		steps.Any().Element("div")
+	}
+	if err := NewStreamSource(strings.NewReader(""), steps).Err; err != ErrTooManyStreamSteps {
+		t.Fatal("too many steps:", err)
+	}
+}
+
+func TestStreamConcurrent(t *testing.T) {
+	doc := NewStreamSource(strings.NewReader(`<a>1</a><a>2</a>`), NewStreamQuery().Any().Element("a"))
+	var wg sync.WaitGroup
+	var consumed, collected int32
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch nodes, err := doc.Collect(); {
+			case err == ErrStreamConsumed:
+				atomic.AddInt32(&consumed, 1)
+			case err == nil && len(nodes) == 2:
+				atomic.AddInt32(&collected, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if collected != 1 || consumed != 3 {
+		t.Fatal("collected:", collected, "consumed:", consumed)
+	}
+}
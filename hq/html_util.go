@@ -17,7 +17,16 @@
 package hq
 
 import (
+	"net/url"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+	"weak"
 
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
@@ -25,33 +34,57 @@ import (
 
 // -----------------------------------------------------------------------------
 
-// ContainsClass checks class v is in source classes or not.
+// ContainsClass checks class v is in source classes or not. Classes are
+// separated by html whitespace.
 func ContainsClass(source string, v string) bool {
-	for {
-		pos := strings.IndexByte(source, ' ')
-		if pos < 0 {
-			return source == v
-		}
-		if source[:pos] == v {
+	for _, class := range splitClasses(source) {
+		if class == v {
 			return true
 		}
-		source = source[pos+1:]
 	}
+	return false
+}
+
+// isHTMLSpace checks if c is a html whitespace (space, tab, LF, FF or CR).
+func isHTMLSpace(c rune) bool {
+	switch c {
+	case ' ', '\t', '\n', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+// splitClasses splits a class attribute value into class names.
+func splitClasses(source string) []string {
+	return strings.FieldsFunc(source, isHTMLSpace)
 }
 
-// AttributeVal returns attribute k's value of a node.
+// AttributeVal returns attribute k's value of a node. Attribute names are
+// case-insensitive.
 func AttributeVal(node *html.Node, k string) (v string, err error) {
 	if node.Type != html.ElementNode {
 		return "", ErrInvalidNode
 	}
 	for _, attr := range node.Attr {
-		if attr.Key == k {
+		if strings.EqualFold(attr.Key, k) {
 			return attr.Val, nil
 		}
 	}
 	return "", ErrNotFound
 }
 
+func attrOr(node *html.Node, k string, defval string) string {
+	if v, err := AttributeVal(node, k); err == nil {
+		return v
+	}
+	return defval
+}
+
+func hasAttr(node *html.Node, k string) bool {
+	_, err := AttributeVal(node, k)
+	return err == nil
+}
+
 // FirstChild returns first nodeType node.
 func FirstChild(node *html.Node, nodeType html.NodeType) (p *html.Node, err error) {
 	for p = node.FirstChild; p != nil; p = p.NextSibling {
@@ -74,6 +107,182 @@ func LastChild(node *html.Node, nodeType html.NodeType) (p *html.Node, err error
 
 // -----------------------------------------------------------------------------
 
+// setSourceURL records url as the source of doc, which must be a new document.
+func setSourceURL(doc *html.Node, url string) {
+	addDocState(doc, &docState{url: url})
+}
+
+// setSourceFile records file as the source of doc, which must be a new document.
+func setSourceFile(doc *html.Node, file string) {
+	addDocState(doc, &docState{file: file})
+}
+
+// sourceName returns the url or the file name of the document which node
+// belongs to, "" if unknown.
+func sourceName(node *html.Node) string {
+	state := docStateOf(node, false)
+	if state == nil {
+		return ""
+	}
+	if state.url != "" {
+		return state.url
+	}
+	return state.file
+}
+
+// docState - state of a document kept by hq, eg. how many times it's mutated.
+// It's kept in docStates rather than in the document, so it's invisible to
+// html.Render and other packages. Fields which can change are atomic, so that
+// readers of a shared document don't lock each other.
+type docState struct {
+	gen    atomic.Int64 // number of mutations, so that indexes know when to be rebuilt
+	frozen atomic.Bool  // see Freeze
+	url    string       // url the document comes from, set on creation
+	file   string       // local file the document comes from, set on creation
+}
+
+// docStates maps weak pointers of documents to their *docState. A state is
+// dropped when its document is collected.
+var docStates sync.Map
+
+// docStateOf returns the state of the document which node belongs to. If the
+// document has no state yet, it's created if create is true, or nil is
+// returned. It returns nil if node doesn't belong to a document.
+func docStateOf(node *html.Node, create bool) *docState {
+	doc := Root(node)
+	if doc.Type != html.DocumentNode {
+		return nil
+	}
+	if v, ok := docStates.Load(weak.Make(doc)); ok || !create {
+		state, _ := v.(*docState)
+		return state
+	}
+	return addDocState(doc, new(docState))
+}
+
+// addDocState stores state as the state of doc unless doc has one, and
+// returns the state of doc.
+func addDocState(doc *html.Node, state *docState) *docState {
+	key := weak.Make(doc)
+	v, loaded := docStates.LoadOrStore(key, state)
+	if !loaded {
+		runtime.AddCleanup(doc, func(key weak.Pointer[html.Node]) {
+			docStates.Delete(key)
+		}, key)
+	}
+	return v.(*docState)
+}
+
+// Root returns the root node (normally a DocumentNode) of the tree node belongs to.
+func Root(node *html.Node) *html.Node {
+	for node.Parent != nil {
+		node = node.Parent
+	}
+	return node
+}
+
+// NodePath returns the path of node from the root of its tree like a XPath,
+// eg. `/html/body/div[2]/a`. A node is indexed (from 1) only if its parent has
+// more than one child with the same name. Text and comment nodes are named
+// `text()` and `comment()`.
+func NodePath(node *html.Node) string {
+	var names []string
+	for ; node != nil && node.Type != html.DocumentNode; node = node.Parent {
+		name := nodeName(node)
+		if node.Parent != nil {
+			n, pos := 0, 0
+			for sibling := node.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+				if sibling.Type == node.Type && nodeName(sibling) == name {
+					if n++; sibling == node {
+						pos = n
+					}
+				}
+			}
+			if n > 1 {
+				name += "[" + strconv.Itoa(pos) + "]"
+			}
+		}
+		names = append(names, name)
+	}
+	var b strings.Builder
+	for i := len(names) - 1; i >= 0; i-- {
+		b.WriteByte('/')
+		b.WriteString(names[i])
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
+
+func nodeName(node *html.Node) string {
+	switch node.Type {
+	case html.ElementNode:
+		return node.Data
+	case html.TextNode:
+		return "text()"
+	case html.CommentNode:
+		return "comment()"
+	}
+	return "node()"
+}
+
+// SourceURL returns the url of the document which node belongs to.
+// It returns "" if the document isn't loaded from a http source.
+func SourceURL(node *html.Node) string {
+	if state := docStateOf(node, false); state != nil {
+		return state.url
+	}
+	return ""
+}
+
+// BaseURL returns the base url of the document which node belongs to.
+// It is the source url resolved with the href of `<base>` element, if any.
+func BaseURL(node *html.Node) string {
+	doc := Root(node)
+	base := SourceURL(doc)
+	if href, ok := baseHref(doc); ok {
+		return resolveURL(base, href)
+	}
+	return base
+}
+
+func baseHref(node *html.Node) (href string, ok bool) {
+	if node.DataAtom == atom.Base {
+		if href, err := AttributeVal(node, "href"); err == nil {
+			return strings.TrimSpace(href), true
+		}
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if href, ok = baseHref(child); ok {
+			return
+		}
+	}
+	return
+}
+
+// ResolveURL resolves ref against the base url of the document which node belongs to.
+func ResolveURL(node *html.Node, ref string) string {
+	return resolveURL(BaseURL(node), ref)
+}
+
+func resolveURL(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.ResolveReference(r).String()
+}
+
+// -----------------------------------------------------------------------------
+
 // ChildEqualText checks if child node is TextNode and value is equal to text or not.
 func ChildEqualText(node *html.Node, text string) bool {
 	p := node.FirstChild
@@ -99,6 +308,68 @@ func ContainsText(node *html.Node, text string) bool {
 	return strings.Contains(node.Data, text)
 }
 
+// MatchText checks if node is TextNode and its normalized value matches re.
+func MatchText(node *html.Node, re *regexp.Regexp) bool {
+	if node.Type != html.TextNode {
+		return false
+	}
+	return re.MatchString(NormalizeText(node.Data))
+}
+
+// ContainsTextFold checks if node is TextNode and its normalized value contains
+// text under case-folding.
+func ContainsTextFold(node *html.Node, text string) bool {
+	if node.Type != html.TextNode {
+		return false
+	}
+	return containsFold(NormalizeText(node.Data), NormalizeText(text))
+}
+
+// containsFold checks if s contains substr under simple Unicode case-folding,
+// as strings.EqualFold does. Eg. "K" (Kelvin sign) contains "k".
+func containsFold(s, substr string) bool {
+	for i := range s {
+		if hasPrefixFold(s[i:], substr) {
+			return true
+		}
+	}
+	return substr == ""
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	for _, pc := range prefix {
+		sc, size := utf8.DecodeRuneInString(s)
+		if size == 0 || !equalFoldRune(sc, pc) {
+			return false
+		}
+		s = s[size:]
+	}
+	return true
+}
+
+func equalFoldRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+// HasText checks if node's text (see Text), normalized, contains text.
+func HasText(node *html.Node, text string) bool {
+	return strings.Contains(NormalizeText(Text(node)), NormalizeText(text))
+}
+
+// NormalizeText replaces non-breaking spaces with spaces, collapses runs of
+// whitespace into one space, and trims leading and trailing whitespace.
+func NormalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ") // unicode.IsSpace includes U+00A0
+}
+
 // ExactText returns a text node's text.
 func ExactText(node *html.Node) (string, error) {
 	if node.Type != html.TextNode {
@@ -0,0 +1,93 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"regexp"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestContainsFold(t *testing.T) {
+	cases := []struct {
+		s, substr string
+		want      bool
+	}{
+		{"Hello World", "WORLD", true},
+		{"Hello World", "o w", true},
+		{"Hello", "", true},
+		{"", "a", false},
+		{"Hello", "hello!", false},
+		{"\u212Aelvin", "kelvin", true}, // Kelvin sign, 3 bytes, folds to k
+		{"kelvin", "KELVIN", true},
+		{"ſtop", "STOP", true},          // long s folds to s, but isn't lowered to s
+		{"ΣΊΣΥΦΟΣ", "σίσυφος", true},    // final sigma folds to Σ, but isn't lowered to σ
+		{"İstanbul", "istanbul", false}, // not a simple folding
+		{"straße", "STRASSE", false},    // not a simple folding
+	}
+	for _, c := range cases {
+		if got := containsFold(c.s, c.substr); got != c.want {
+			t.Errorf("containsFold(%q, %q) = %v", c.s, c.substr, got)
+		}
+	}
+}
+
+const nbspPage = `<ul>
+<li><b>Price:</b>&nbsp;12&nbsp;USD</li>
+<li>Temperature:&nbsp;&nbsp;300&nbsp;&#x212A;</li>
+</ul>`
+
+func TestTextMatchers(t *testing.T) {
+	doc := Source.String(nbspPage)
+	texts := func(ns NodeSet) (ret []string) {
+		nodes, err := ns.Collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, node := range nodes {
+			if node.Type == html.TextNode {
+				ret = append(ret, node.Data)
+			} else {
+				ret = append(ret, node.Data+":"+NormalizeText(Text(node)))
+			}
+		}
+		return
+	}
+
+	if got := texts(doc.Any().ContainsTextFold("temperature: 300 k")); len(got) != 1 {
+		t.Fatal("ContainsTextFold:", got)
+	}
+	if got := texts(doc.Any().MatchText(regexp.MustCompile(`^12 USD$`))); len(got) != 1 {
+		t.Fatal("MatchText:", got)
+	}
+	if got := texts(doc.Any().Li().HasText("Price: 12 USD")); len(got) != 1 || got[0] != "li:Price: 12 USD" {
+		t.Fatal("HasText:", got)
+	}
+	if got := texts(doc.Any().Li().HasText("Price: 12")); len(got) != 1 {
+		t.Fatal("HasText with nbsp:", got)
+	}
+
+	re := regexp.MustCompile(`^Price: (\d+) (\w+)$`)
+	submatch, err := doc.Any().Li().TextSubmatch(re)
+	if err != nil || len(submatch) != 3 || submatch[1] != "12" || submatch[2] != "USD" {
+		t.Fatal("TextSubmatch:", submatch, err)
+	}
+	if _, err = doc.Any().Li().NextSiblings().Li().TextSubmatch(re); err == nil {
+		t.Fatal("TextSubmatch: unmatched text")
+	}
+}
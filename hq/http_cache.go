@@ -0,0 +1,351 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCacheMiss - not in cache (in offline mode)
+	ErrCacheMiss = errors.New("not in http cache")
+)
+
+const (
+	cacheStoredAt = "X-Hq-Stored-At"
+
+	// CacheStatusHeader is set to responses served from cache, whose value is
+	// "HIT" (fresh or offline) or "REVALIDATED".
+	CacheStatusHeader = "X-Hq-Cache"
+)
+
+// -----------------------------------------------------------------------------
+
+// HTTPCache - a storage of http cache entries.
+type HTTPCache interface {
+	Get(key string) (data []byte, ok bool)
+	Set(key string, data []byte)
+	Delete(key string)
+}
+
+// -----------------------------------------------------------------------------
+
+type memCacheEntry struct {
+	key  string
+	data []byte
+}
+
+// MemoryCache - an in-memory LRU http cache.
+type MemoryCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	lru        *list.List
+	entries    map[string]*list.Element
+}
+
+// NewMemoryCache creates an in-memory LRU http cache. If maxEntries is 0,
+// entries are never evicted.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get gets a cache entry.
+func (p *MemoryCache) Get(key string) (data []byte, ok bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	p.lru.MoveToFront(e)
+	return e.Value.(*memCacheEntry).data, true
+}
+
+// Set sets a cache entry.
+func (p *MemoryCache) Set(key string, data []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if e, ok := p.entries[key]; ok {
+		e.Value.(*memCacheEntry).data = data
+		p.lru.MoveToFront(e)
+		return
+	}
+	p.entries[key] = p.lru.PushFront(&memCacheEntry{key, data})
+	if p.maxEntries > 0 && p.lru.Len() > p.maxEntries {
+		e := p.lru.Back()
+		p.lru.Remove(e)
+		delete(p.entries, e.Value.(*memCacheEntry).key)
+	}
+}
+
+// Delete deletes a cache entry.
+func (p *MemoryCache) Delete(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if e, ok := p.entries[key]; ok {
+		p.lru.Remove(e)
+		delete(p.entries, key)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// DiskCache - an on-disk http cache, one file per entry in a directory.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache creates an on-disk http cache in dir.
+func NewDiskCache(dir string) (ret *DiskCache, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	return &DiskCache{dir}, nil
+}
+
+func (p *DiskCache) path(key string) string {
+	h := sha1.Sum([]byte(key))
+	return filepath.Join(p.dir, hex.EncodeToString(h[:]))
+}
+
+// Get gets a cache entry.
+func (p *DiskCache) Get(key string) (data []byte, ok bool) {
+	data, err := ioutil.ReadFile(p.path(key))
+	return data, err == nil
+}
+
+// Set sets a cache entry.
+func (p *DiskCache) Set(key string, data []byte) {
+	f, err := ioutil.TempFile(p.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// Delete deletes a cache entry.
+func (p *DiskCache) Delete(key string) {
+	os.Remove(p.path(key))
+}
+
+// -----------------------------------------------------------------------------
+
+// CacheTransport - a http.RoundTripper which caches GET responses.
+//
+// It honours `Cache-Control` (no-store, no-cache, max-age) and `Expires`, and
+// revalidates stale entries with `If-None-Match`/`If-Modified-Since`. `Vary`
+// isn't supported: entries are keyed by url only.
+//
+// To use it with hq sources:
+//
+//	src := hq.SourceCreator{Client: &http.Client{Transport: hq.NewCacheTransport(cache)}}
+//	doc := src.HTTP(url)
+type CacheTransport struct {
+	Transport http.RoundTripper // underlying transport, http.DefaultTransport if nil
+	Cache     HTTPCache
+	Offline   bool // serves only from cache, and returns ErrCacheMiss if not cached
+}
+
+// NewCacheTransport creates a CacheTransport.
+func NewCacheTransport(cache HTTPCache) *CacheTransport {
+	return &CacheTransport{Cache: cache}
+}
+
+func (p *CacheTransport) transport() http.RoundTripper {
+	if p.Transport != nil {
+		return p.Transport
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *CacheTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if req.Method != "GET" {
+		if p.Offline {
+			return nil, ErrCacheMiss
+		}
+		return p.transport().RoundTrip(req)
+	}
+	key := req.URL.String()
+	cached, storedAt := p.load(key, req)
+	if p.Offline {
+		if cached == nil {
+			return nil, ErrCacheMiss
+		}
+		cached.Header.Set(CacheStatusHeader, "HIT")
+		return cached, nil
+	}
+	outreq := req
+	if cached != nil {
+		reqcc := parseCacheControl(req.Header)
+		if _, nocache := reqcc["no-cache"]; !nocache && isFresh(cached, storedAt) {
+			cached.Header.Set(CacheStatusHeader, "HIT")
+			return cached, nil
+		}
+		etag, lastModified := cached.Header.Get("Etag"), cached.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outreq = req.Clone(req.Context())
+			if etag != "" {
+				outreq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outreq.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+	if resp, err = p.transport().RoundTrip(outreq); err != nil {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return
+	}
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+		p.store(key, cached)
+		cached.Header.Set(CacheStatusHeader, "REVALIDATED")
+		return cached, nil
+	}
+	if cached != nil {
+		cached.Body.Close()
+	}
+	if isCacheable(req, resp) {
+		p.store(key, resp)
+	} else {
+		p.Cache.Delete(key)
+	}
+	return resp, nil
+}
+
+func (p *CacheTransport) load(key string, req *http.Request) (resp *http.Response, storedAt time.Time) {
+	data, ok := p.Cache.Get(key)
+	if !ok {
+		return
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		p.Cache.Delete(key)
+		return nil, storedAt
+	}
+	nsec, _ := strconv.ParseInt(resp.Header.Get(cacheStoredAt), 10, 64)
+	resp.Header.Del(cacheStoredAt)
+	return resp, time.Unix(0, nsec)
+}
+
+// store saves resp into cache. resp.Body is replaced so that it can still be read.
+func (p *CacheTransport) store(key string, resp *http.Response) {
+	resp.Header.Set(cacheStoredAt, strconv.FormatInt(time.Now().UnixNano(), 10))
+	resp.Header.Del(CacheStatusHeader)
+	data, err := httputil.DumpResponse(resp, true)
+	resp.Header.Del(cacheStoredAt)
+	if err != nil {
+		return
+	}
+	p.Cache.Set(key, data)
+}
+
+func isCacheable(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case 200, 203, 300, 301, 404, 410:
+	default:
+		return false
+	}
+	if _, ok := parseCacheControl(req.Header)["no-store"]; ok {
+		return false
+	}
+	_, ok := parseCacheControl(resp.Header)["no-store"]
+	return !ok
+}
+
+func isFresh(resp *http.Response, storedAt time.Time) bool {
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+	age := time.Since(storedAt)
+	if v, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		age += time.Duration(v) * time.Second
+	}
+	var lifetime time.Duration
+	if v, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return false
+		}
+		lifetime = time.Duration(secs) * time.Second
+	} else if v := resp.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return false
+		}
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			date = storedAt
+		}
+		lifetime = expires.Sub(date)
+	}
+	return lifetime > age
+}
+
+func parseCacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if pos := strings.IndexByte(part, '='); pos >= 0 {
+				cc[strings.ToLower(strings.TrimSpace(part[:pos]))] = strings.Trim(strings.TrimSpace(part[pos+1:]), `"`)
+			} else {
+				cc[strings.ToLower(part)] = ""
+			}
+		}
+	}
+	return cc
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,229 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cacheOrigin is an origin server which counts hits of each path.
+type cacheOrigin struct {
+	*httptest.Server
+	mutex sync.Mutex
+	hits  map[string]int
+}
+
+func newCacheOrigin() *cacheOrigin {
+	p := &cacheOrigin{hits: make(map[string]int)}
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mutex.Lock()
+		p.hits[r.URL.Path]++
+		n := p.hits[r.URL.Path]
+		p.mutex.Unlock()
+		h := w.Header()
+		switch r.URL.Path {
+		case "/max-age":
+			h.Set("Cache-Control", "max-age=60")
+		case "/expires":
+			h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+			h.Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		case "/expired":
+			h.Set("Expires", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		case "/etag":
+			h.Set("Cache-Control", "no-cache")
+			h.Set("Etag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				h.Set("X-Version", fmt.Sprint(n))
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/last-modified":
+			h.Set("Cache-Control", "max-age=0")
+			h.Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			h.Set("Cache-Control", "max-age=60, no-store")
+		}
+		fmt.Fprintf(w, "%s %d", r.URL.Path, n)
+	}))
+	return p
+}
+
+func (p *cacheOrigin) hitsOf(path string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.hits[path]
+}
+
+// cacheGet gets url by rt, and returns the body and the cache status header.
+func cacheGet(t *testing.T, rt http.RoundTripper, url string) (body, status string) {
+	resp, err := (&http.Client{Transport: rt}).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), resp.Header.Get(CacheStatusHeader)
+}
+
+func TestCacheTransport(t *testing.T) {
+	origin := newCacheOrigin()
+	defer origin.Close()
+
+	cases := []struct {
+		path   string
+		status string // status of the second get
+		body   string // body of the second get
+		hits   int
+	}{
+		{"/max-age", "HIT", "/max-age 1", 1},
+		{"/expires", "HIT", "/expires 1", 1},
+		{"/expired", "", "/expired 2", 2},
+		{"/etag", "REVALIDATED", "/etag 1", 2},
+		{"/last-modified", "REVALIDATED", "/last-modified 1", 2},
+		{"/no-store", "", "/no-store 2", 2},
+	}
+	rt := NewCacheTransport(NewMemoryCache(0))
+	for _, c := range cases {
+		if body, status := cacheGet(t, rt, origin.URL+c.path); status != "" || body != c.path+" 1" {
+			t.Fatalf("%s: first get: %q %q", c.path, body, status)
+		}
+		body, status := cacheGet(t, rt, origin.URL+c.path)
+		if status != c.status || body != c.body || origin.hitsOf(c.path) != c.hits {
+			t.Errorf("%s: got %q %q and %d hits, want %q %q and %d hits",
+				c.path, body, status, origin.hitsOf(c.path), c.body, c.status, c.hits)
+		}
+	}
+
+	resp, err := (&http.Client{Transport: rt}).Get(origin.URL + "/etag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if v := resp.Header.Get("X-Version"); v != "3" { // headers of 304 are merged
+		t.Fatal("merged header:", v)
+	}
+	if data, _ := rt.Cache.Get(origin.URL + "/etag"); !bytes.Contains(data, []byte("X-Version: 3")) {
+		t.Fatal("cached entry isn't updated:", string(data))
+	}
+
+	req, _ := http.NewRequest("GET", origin.URL+"/max-age", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	resp, err = rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if n := origin.hitsOf("/max-age"); n != 2 {
+		t.Fatal("no-cache request:", n)
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	origin := newCacheOrigin()
+	defer origin.Close()
+
+	rt := NewCacheTransport(NewMemoryCache(0))
+	cacheGet(t, rt, origin.URL+"/expired")
+	rt.Offline = true
+	if body, status := cacheGet(t, rt, origin.URL+"/expired"); status != "HIT" || body != "/expired 1" {
+		t.Fatal("stale entry:", body, status)
+	}
+	_, err := (&http.Client{Transport: rt}).Get(origin.URL + "/max-age")
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Fatal("missing entry:", err)
+	}
+	if _, err = (&http.Client{Transport: rt}).PostForm(origin.URL+"/max-age", nil); !errors.Is(err, ErrCacheMiss) {
+		t.Fatal("POST:", err)
+	}
+	if n := origin.hitsOf("/expired") + origin.hitsOf("/max-age"); n != 1 {
+		t.Fatal("origin is hit offline:", n)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	origin := newCacheOrigin()
+	defer origin.Close()
+
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ { // the second instance reads entries of the first one
+		cache, err := NewDiskCache(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, status := cacheGet(t, NewCacheTransport(cache), origin.URL+"/max-age")
+		if want := []string{"", "HIT"}[i]; status != want || body != "/max-age 1" {
+			t.Fatalf("instance %d: %q %q", i, body, status)
+		}
+	}
+	if n := origin.hitsOf("/max-age"); n != 1 {
+		t.Fatal("hits:", n)
+	}
+
+	cache, _ := NewDiskCache(dir)
+	cache.Set("k", []byte("v"))
+	if data, ok := cache.Get("k"); !ok || string(data) != "v" {
+		t.Fatal("Get:", string(data), ok)
+	}
+	cache.Delete("k")
+	if _, ok := cache.Get("k"); ok {
+		t.Fatal("deleted entry is got")
+	}
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("a", []byte("1"))
+	cache.Set("b", []byte("2"))
+	cache.Get("a") // b is the least recently used now
+	cache.Set("c", []byte("3"))
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("b isn't evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Fatal(key, "is evicted")
+		}
+	}
+	cache.Set("a", []byte("4")) // a is updated and used, so d evicts c
+	cache.Set("d", []byte("5"))
+	if data, ok := cache.Get("a"); !ok || string(data) != "4" {
+		t.Fatal("a:", string(data), ok)
+	}
+	if _, ok := cache.Get("c"); ok {
+		t.Fatal("c isn't evicted")
+	}
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("deleted entry is got")
+	}
+}
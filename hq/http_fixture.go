@@ -0,0 +1,193 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNoFixture - no recorded fixture matches the request
+	ErrNoFixture = errors.New("no recorded fixture")
+)
+
+// FixtureMode - mode of FixtureTransport.
+type FixtureMode int
+
+const (
+	// FixtureReplay serves responses from recorded fixtures only.
+	FixtureReplay FixtureMode = iota
+	// FixtureRecord sends requests and records responses as fixtures.
+	FixtureRecord
+	// FixtureAuto serves recorded fixtures, and records missing ones.
+	FixtureAuto
+)
+
+// -----------------------------------------------------------------------------
+
+// FixtureTransport - a http.RoundTripper which records http responses into a
+// directory, and replays them, so scrapers can be tested offline with real
+// page snapshots.
+//
+// Each fixture is a file holding the raw response, named by the request's
+// host and path plus a hash of its method, url and body (with the multipart
+// boundary replaced, since it's random), for example
+// `example.com_list-3f2a9c0d1b7e.http`.
+//
+// To use it with hq sources:
+//
+//	src := hq.SourceCreator{Client: &http.Client{Transport: hq.NewReplayer("testdata")}}
+type FixtureTransport struct {
+	Dir       string
+	Mode      FixtureMode
+	Transport http.RoundTripper // underlying transport for recording, http.DefaultTransport if nil
+
+	mutex     sync.Mutex
+	unmatched []string
+}
+
+// NewRecorder creates a FixtureTransport to record fixtures into dir.
+func NewRecorder(dir string) *FixtureTransport {
+	return &FixtureTransport{Dir: dir, Mode: FixtureRecord}
+}
+
+// NewReplayer creates a FixtureTransport to replay fixtures in dir.
+func NewReplayer(dir string) *FixtureTransport {
+	return &FixtureTransport{Dir: dir, Mode: FixtureReplay}
+}
+
+// Unmatched returns requests (as "METHOD url") which have no fixture in replay mode.
+func (p *FixtureTransport) Unmatched() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.unmatched...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *FixtureTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	body, consumed, err := requestBody(req)
+	if err != nil {
+		return
+	}
+	file := p.fixtureFile(req, body)
+	if p.Mode != FixtureRecord {
+		data, err := ioutil.ReadFile(file)
+		if err == nil || p.Mode == FixtureReplay || !os.IsNotExist(err) {
+			if !consumed && req.Body != nil {
+				req.Body.Close() // a RoundTripper must close the body, even on errors
+			}
+			if err == nil {
+				return http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+			}
+			what := req.Method + " " + req.URL.String()
+			p.mutex.Lock()
+			p.unmatched = append(p.unmatched, what)
+			p.mutex.Unlock()
+			return nil, fmt.Errorf("%w: %s (%s)", ErrNoFixture, what, filepath.Base(file))
+		}
+	}
+	if consumed { // send a copy, since a RoundTripper mustn't modify req
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	t := p.Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	if resp, err = t.RoundTrip(req); err != nil {
+		return
+	}
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if err = os.MkdirAll(p.Dir, 0755); err == nil {
+		err = ioutil.WriteFile(file, data, 0644)
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return
+}
+
+// requestBody returns the body of req. It uses req.GetBody if possible, so
+// that req.Body isn't consumed. Otherwise it reads and closes req.Body, and
+// consumed is true.
+func requestBody(req *http.Request) (body []byte, consumed bool, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			req.Body.Close()
+			return nil, true, err
+		}
+		defer rc.Close()
+		body, err = ioutil.ReadAll(rc)
+		return body, false, err
+	}
+	body, err = ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	return body, true, err
+}
+
+// fixtureFile returns the fixture file of req. The random boundary of a
+// multipart body is replaced before hashing, so that the same form submitted
+// again matches the fixture.
+func (p *FixtureTransport) fixtureFile(req *http.Request, body []byte) string {
+	h := sha1.New()
+	h.Write([]byte(req.Method + " " + req.URL.String() + "\n"))
+	if mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil &&
+		strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("boundary"))
+	}
+	h.Write(body)
+	name := fixtureName(req.URL.Host + req.URL.Path)
+	return filepath.Join(p.Dir, name+"-"+hex.EncodeToString(h.Sum(nil))[:12]+".http")
+}
+
+func fixtureName(s string) string {
+	name := strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-':
+			return c
+		}
+		return '_'
+	}, strings.TrimSuffix(s, "/"))
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// -----------------------------------------------------------------------------
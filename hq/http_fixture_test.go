@@ -0,0 +1,112 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newEchoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		fmt.Fprintf(w, "<p>%s %s q=%s</p>", r.Method, r.URL.Path, r.FormValue("q"))
+	}))
+}
+
+func TestFixtureRecordReplay(t *testing.T) {
+	srv := newEchoServer()
+	dir := t.TempDir()
+	form, err := Source.String(`<form action="` + srv.URL + `/post" method="post" enctype="multipart/form-data">
+		<input name="q" value="x"><input type="file" name="f"></form>`).Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	form.SetFile("f", "a.txt", []byte("data"))
+
+	run := func(rt http.RoundTripper) (page, posted string) {
+		client := &http.Client{Transport: rt}
+		src := SourceCreator{Client: client}
+		page, err := src.HTTP(srv.URL + "/a/b").Any().P().Text()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if posted, err = form.Submit(client).Any().P().Text(); err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(page), strings.TrimSpace(posted)
+	}
+	page, posted := run(NewRecorder(dir))
+	if page != "GET /a/b q=" || posted != "POST /post q=x" {
+		t.Fatal("record:", page, posted)
+	}
+	srv.Close()
+
+	rp := NewReplayer(dir)
+	if page2, posted2 := run(rp); page2 != page || posted2 != posted {
+		t.Fatal("replay:", page2, posted2)
+	}
+	if n := len(rp.Unmatched()); n != 0 {
+		t.Fatal("unmatched:", rp.Unmatched())
+	}
+
+	err = SourceCreator{Client: &http.Client{Transport: rp}}.HTTP(srv.URL + "/c").Err
+	if !errors.Is(err, ErrNoFixture) {
+		t.Fatal("missing fixture:", err)
+	}
+	for _, um := range rp.Unmatched() { // HTTP retries once
+		if um != "GET "+srv.URL+"/c" {
+			t.Fatal("unmatched:", um)
+		}
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (p *closeRecorder) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestFixtureRequestUnchanged(t *testing.T) {
+	srv := newEchoServer()
+	defer srv.Close()
+	body := &closeRecorder{Reader: strings.NewReader("q=y")}
+	req, _ := http.NewRequest("POST", srv.URL+"/form", body)
+	req.Header.Set("Content-Type", EnctypeURLEncoded)
+	resp, err := NewRecorder(t.TempDir()).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "<p>POST /form q=y</p>" {
+		t.Fatal("response:", string(data))
+	}
+	if req.Body != body || !body.closed {
+		t.Fatal("request body is replaced or not closed")
+	}
+}
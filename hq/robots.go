@@ -0,0 +1,308 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDisallowedByRobots - disallowed by robots.txt
+	ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+)
+
+// -----------------------------------------------------------------------------
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// RobotsTxt - a parsed robots.txt.
+type RobotsTxt struct {
+	groups []*robotsGroup
+}
+
+// ParseRobotsTxt parses a robots.txt.
+func ParseRobotsTxt(r io.Reader) (ret *RobotsTxt, err error) {
+	ret = new(RobotsTxt)
+	var group *robotsGroup
+	inAgents := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if pos := strings.IndexByte(line, '#'); pos >= 0 {
+			line = line[:pos]
+		}
+		pos := strings.IndexByte(line, ':')
+		if pos < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:pos]))
+		val := strings.TrimSpace(line[pos+1:])
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				group = new(robotsGroup)
+				ret.groups = append(ret.groups, group)
+				inAgents = true
+			}
+			group.agents = append(group.agents, strings.ToLower(val))
+			continue
+		case "allow", "disallow":
+			if group != nil && val != "" {
+				group.rules = append(group.rules, robotsRule{key == "allow", val})
+			}
+		case "crawl-delay":
+			if group != nil {
+				if secs, err := strconv.ParseFloat(val, 64); err == nil && secs > 0 {
+					group.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+		inAgents = false
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// group returns the group for userAgent: the one with the longest matched
+// agent name, or the `*` group.
+func (p *RobotsTxt) group(userAgent string) (ret *robotsGroup) {
+	userAgent = strings.ToLower(userAgent)
+	best := -1
+	for _, group := range p.groups {
+		for _, agent := range group.agents {
+			switch {
+			case agent == "*":
+				if best < 0 {
+					ret, best = group, 0
+				}
+			case strings.Contains(userAgent, agent):
+				if len(agent) > best {
+					ret, best = group, len(agent)
+				}
+			}
+		}
+	}
+	return
+}
+
+// Allowed checks if userAgent is allowed to fetch path (with query) or not.
+// The longest matched rule wins, and Allow wins if lengths are equal.
+func (p *RobotsTxt) Allowed(userAgent, path string) bool {
+	group := p.group(userAgent)
+	if group == nil {
+		return true
+	}
+	allow, best := true, -1
+	for _, rule := range group.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || (n == best && rule.allow) {
+			allow, best = rule.allow, n
+		}
+	}
+	return allow
+}
+
+// CrawlDelay returns Crawl-delay for userAgent.
+func (p *RobotsTxt) CrawlDelay(userAgent string) time.Duration {
+	if group := p.group(userAgent); group != nil {
+		return group.crawlDelay
+	}
+	return 0
+}
+
+// matchRobotsPattern matches path with pattern, where `*` matches any
+// sequence of characters and a trailing `$` anchors the end of path.
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || path == ""
+	}
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(path, part)
+		}
+		pos := strings.Index(path, part)
+		if pos < 0 {
+			return false
+		}
+		path = path[pos+len(part):]
+	}
+	return true
+}
+
+// -----------------------------------------------------------------------------
+
+// Robots - a robots.txt policy which fetches and caches robots.txt per host.
+type Robots struct {
+	UserAgent string       // user agent to match robots.txt groups, and to send
+	Client    *http.Client // client to fetch robots.txt, http.DefaultClient if nil
+
+	// RetryInterval is the interval to fetch an unreachable robots.txt again,
+	// 1 minute if 0. All urls of the host are disallowed before that.
+	RetryInterval time.Duration
+
+	mutex sync.Mutex
+	hosts map[string]*robotsHost
+}
+
+type robotsHost struct {
+	mutex   sync.Mutex // protects fields below, and is held while fetching robots.txt
+	fetched time.Time  // when robots.txt was fetched, zero if not yet
+	txt     *RobotsTxt // nil means disallowing all
+	lastHit time.Time  // time of the last request allowed by Check
+}
+
+// NewRobots creates a robots.txt policy for userAgent.
+func NewRobots(userAgent string) *Robots {
+	return &Robots{UserAgent: userAgent}
+}
+
+// host returns the host of u and its robots.txt, which is fetched if it isn't
+// fetched yet, or was unreachable RetryInterval ago.
+func (p *Robots) host(ctx context.Context, u *url.URL) (h *robotsHost, txt *RobotsTxt) {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+	p.mutex.Lock()
+	if p.hosts == nil {
+		p.hosts = make(map[string]*robotsHost)
+	}
+	h, ok := p.hosts[key]
+	if !ok {
+		h = new(robotsHost)
+		p.hosts[key] = h
+	}
+	p.mutex.Unlock()
+
+	retry := p.RetryInterval
+	if retry <= 0 {
+		retry = time.Minute
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.fetched.IsZero() || h.txt == nil && time.Since(h.fetched) >= retry {
+		txt := p.fetch(ctx, key+"/robots.txt")
+		if ctx.Err() != nil { // canceled, not unreachable
+			return h, nil
+		}
+		h.txt, h.fetched = txt, time.Now()
+	}
+	return h, h.txt
+}
+
+// fetch fetches a robots.txt. A missing robots.txt (4xx) allows all, and an
+// unreachable one (network errors, 5xx) disallows all.
+func (p *Robots) fetch(ctx context.Context, robotsURL string) *RobotsTxt {
+	req, err := http.NewRequest("GET", robotsURL, nil)
+	if err != nil {
+		return nil
+	}
+	if p.UserAgent != "" {
+		req.Header.Set("User-Agent", p.UserAgent)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		txt, err := ParseRobotsTxt(resp.Body)
+		if err != nil {
+			return nil
+		}
+		return txt
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return new(RobotsTxt)
+	}
+	return nil
+}
+
+// Allowed checks if u is allowed to fetch or not.
+func (p *Robots) Allowed(u *url.URL) bool {
+	_, txt := p.host(context.Background(), u)
+	return txt != nil && txt.Allowed(p.UserAgent, u.RequestURI())
+}
+
+// Check returns ErrDisallowedByRobots if u isn't allowed to fetch. Otherwise
+// it waits for the Crawl-delay since the last request to the same host. It
+// returns ctx.Err() if ctx is done while fetching robots.txt or waiting.
+func (p *Robots) Check(ctx context.Context, u *url.URL) error {
+	h, txt := p.host(ctx, u)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if txt == nil || !txt.Allowed(p.UserAgent, u.RequestURI()) {
+		return ErrDisallowedByRobots
+	}
+	delay := txt.CrawlDelay(p.UserAgent)
+	if delay <= 0 {
+		return nil
+	}
+	now := time.Now()
+	h.mutex.Lock()
+	at := h.lastHit.Add(delay)
+	if at.Before(now) {
+		at = now
+	}
+	h.lastHit = at // reserve the time slot, so that the mutex isn't held while waiting
+	h.mutex.Unlock()
+	if d := at.Sub(now); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
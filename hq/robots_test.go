@@ -0,0 +1,168 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package hq
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMatchRobotsPattern(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"/", "/any", true},
+		{"/private", "/private/a", true},
+		{"/private", "/privat", false},
+		{"/*.pdf", "/a/b.pdf?x=1", true},
+		{"/*.pdf$", "/a/b.pdf", true},
+		{"/*.pdf$", "/a/b.pdf?x=1", false},
+		{"/a$", "/a", true},
+		{"/a$", "/ab", false},
+		{"/x*y*z", "/xaaybbz", true},
+		{"/x*y*z", "/xaazbby", false},
+		{"/x*y$", "/xyay", true},
+		{"*", "/", true},
+	}
+	for _, c := range cases {
+		if got := matchRobotsPattern(c.pattern, c.path); got != c.match {
+			t.Errorf("matchRobotsPattern(%q, %q) = %v", c.pattern, c.path, got)
+		}
+	}
+}
+
+const robotsTxt = `# comment
+User-agent: *
+Disallow: /private
+Allow: /private/ok
+Disallow: /*.pdf$
+Allow: /page
+Disallow: /page   # same length: Allow wins
+
+User-agent: MyBot
+User-agent: other
+Disallow: /x*y
+Crawl-delay: 0.5
+`
+
+func TestParseRobotsTxt(t *testing.T) {
+	txt, err := ParseRobotsTxt(strings.NewReader(robotsTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		agent, path string
+		allowed     bool
+	}{
+		{"Foo", "/private/a", false},
+		{"Foo", "/private/ok/1", true}, // longer rule wins
+		{"Foo", "/a.pdf", false},
+		{"Foo", "/a.pdf?x", true},
+		{"Foo", "/page", true},
+		{"MyBot/1.0", "/private", true}, // its own group, not `*`
+		{"MyBot/1.0", "/xaay", false},
+		{"Other", "/xa", true},
+	}
+	for _, c := range cases {
+		if got := txt.Allowed(c.agent, c.path); got != c.allowed {
+			t.Errorf("Allowed(%q, %q) = %v", c.agent, c.path, got)
+		}
+	}
+	if d := txt.CrawlDelay("mybot"); d != 500*time.Millisecond {
+		t.Error("CrawlDelay:", d)
+	}
+	if d := txt.CrawlDelay("foo"); d != 0 {
+		t.Error("CrawlDelay:", d)
+	}
+}
+
+func TestRobotsRetry(t *testing.T) {
+	var hits, failing int32 = 0, 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			atomic.AddInt32(&hits, 1)
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "User-agent: *\nDisallow: /no\n")
+			return
+		}
+		fmt.Fprint(w, "<p>"+r.UserAgent()+"</p>")
+	}))
+	defer srv.Close()
+
+	robots := &Robots{UserAgent: "mybot", RetryInterval: 20 * time.Millisecond}
+	src := SourceCreator{Robots: robots}
+	if err := src.HTTP(srv.URL + "/yes").Err; err != ErrDisallowedByRobots {
+		t.Fatal("unreachable robots.txt:", err)
+	}
+	atomic.StoreInt32(&failing, 0)
+	if err := src.HTTP(srv.URL + "/yes").Err; err != ErrDisallowedByRobots { // not retried yet
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if text, err := src.HTTP(srv.URL + "/yes").Any().P().Text(); err != nil || strings.TrimSpace(text) != "mybot" {
+		t.Fatal(text, err)
+	}
+	if err := src.HTTP(srv.URL + "/no").Err; err != ErrDisallowedByRobots {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/yes", nil)
+	if text, err := src.Do(nil, req).Any().P().Text(); err != nil || strings.TrimSpace(text) != "mybot" {
+		t.Fatal(text, err)
+	}
+	if agent := req.Header.Get("User-Agent"); agent != "" {
+		t.Fatal("request of the caller is modified:", agent)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Fatal("robots.txt fetched", n, "times")
+	}
+}
+
+func TestRobotsCheckCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nCrawl-delay: 10\n")
+	}))
+	defer srv.Close()
+
+	robots := NewRobots("mybot")
+	u, _ := url.Parse(srv.URL + "/a")
+	if err := robots.Check(context.Background(), u); err != nil { // the first request doesn't wait
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := robots.Check(ctx, u); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatal("Check waited", d)
+	}
+	if !robots.Allowed(u) {
+		t.Fatal("Allowed")
+	}
+}
@@ -0,0 +1,425 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qiniu/goplus-dt/export"
+	"github.com/qiniu/goplus-dt/hq"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+
+// Error - an error of a rule file, pointing to the line where it occurs.
+type Error struct {
+	File   string
+	Line   int
+	Column int // 0 if unknown, eg. of YAML syntax errors
+	Msg    string
+}
+
+func (p *Error) Error() string {
+	if p.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Msg)
+}
+
+// yamlErrorLine matches the line of a YAML syntax error, eg. `yaml: line 3: ...`.
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+func syntaxError(file string, err error) error {
+	e := &Error{File: file, Line: 1, Msg: err.Error()}
+	if m := yamlErrorLine.FindStringSubmatch(e.Msg); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Msg = e.Msg[len(m[0]):]
+	}
+	return e
+}
+
+type parser struct {
+	file string
+}
+
+func (p *parser) errorf(node *yaml.Node, format string, args ...interface{}) error {
+	return &Error{File: p.file, Line: node.Line, Column: node.Column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Load loads a rule file (in YAML or JSON).
+func Load(file string) (ret *Rules, err error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	return Parse(data, file)
+}
+
+// Parse parses rules (in YAML or JSON). file is used in error messages.
+func Parse(data []byte, file string) (ret *Rules, err error) {
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, syntaxError(file, err)
+	}
+	p := &parser{file: file}
+	if len(doc.Content) == 0 {
+		return nil, &Error{File: file, Line: 1, Msg: "empty rule file"}
+	}
+	return p.rules(doc.Content[0])
+}
+
+func (p *parser) mapping(node *yaml.Node, fn func(key string, k, v *yaml.Node) error) error {
+	if node.Kind != yaml.MappingNode {
+		return p.errorf(node, "expect a mapping")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		if err := fn(k.Value, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) str(node *yaml.Node) (string, error) {
+	if node.Kind != yaml.ScalarNode {
+		return "", p.errorf(node, "expect a string")
+	}
+	return node.Value, nil
+}
+
+func (p *parser) int(node *yaml.Node) (int, error) {
+	if node.Kind == yaml.ScalarNode {
+		if v, err := strconv.Atoi(node.Value); err == nil {
+			return v, nil
+		}
+	}
+	return 0, p.errorf(node, "expect an integer")
+}
+
+func (p *parser) rules(node *yaml.Node) (ret *Rules, err error) {
+	ret = &Rules{output: output{format: "jsonl"}}
+	var items, fields *yaml.Node
+	err = p.mapping(node, func(key string, k, v *yaml.Node) (err error) {
+		switch key {
+		case "source":
+			var src string
+			if src, err = p.str(v); err == nil {
+				ret.Sources = append(ret.Sources, src)
+			}
+		case "sources":
+			if v.Kind != yaml.SequenceNode {
+				return p.errorf(v, "expect a list of sources")
+			}
+			for _, item := range v.Content {
+				src, err := p.str(item)
+				if err != nil {
+					return err
+				}
+				ret.Sources = append(ret.Sources, src)
+			}
+		case "items":
+			items = v
+			ret.items, err = p.selector(v)
+		case "fields":
+			fields = v
+			ret.cols, err = p.fields(v)
+		case "paginate":
+			ret.paginate, err = p.paginate(v)
+		case "output":
+			err = p.output(v, &ret.output)
+		default:
+			err = p.errorf(k, "unknown key %q", key)
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(ret.Sources) == 0:
+		return nil, p.errorf(node, "no source")
+	case items == nil:
+		return nil, p.errorf(node, "no items")
+	case fields == nil:
+		return nil, p.errorf(node, "no fields")
+	}
+	return
+}
+
+func (p *parser) fields(node *yaml.Node) (cols []export.Column, err error) {
+	if node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+		return nil, p.errorf(node, "expect a list of fields")
+	}
+	names := make(map[string]bool)
+	for _, item := range node.Content {
+		var col export.Column
+		col.Conv = export.Text
+		err = p.mapping(item, func(key string, k, v *yaml.Node) (err error) {
+			switch key {
+			case "name":
+				col.Name, err = p.str(v)
+			case "select":
+				col.Select, err = p.selector(v)
+			case "conv":
+				col.Conv, err = p.conv(v)
+			default:
+				err = p.errorf(k, "unknown key %q", key)
+			}
+			return
+		})
+		if err != nil {
+			return
+		}
+		if col.Name == "" {
+			return nil, p.errorf(item, "field without name")
+		}
+		if names[col.Name] {
+			return nil, p.errorf(item, "duplicated field %q", col.Name)
+		}
+		names[col.Name] = true
+		cols = append(cols, col)
+	}
+	return
+}
+
+// conv parses a conversion: text, int, unitedfloat, html, `scan:<format>` or `attr:<name>`.
+func (p *parser) conv(node *yaml.Node) (conv export.Conv, err error) {
+	s, err := p.str(node)
+	if err != nil {
+		return
+	}
+	switch {
+	case s == "text":
+		return export.Text, nil
+	case s == "int":
+		return export.Int, nil
+	case s == "unitedfloat":
+		return export.UnitedFloat, nil
+	case s == "html":
+		return func(ns hq.NodeSet) (interface{}, error) {
+			return ns.OuterHTML()
+		}, nil
+	case strings.HasPrefix(s, "scan:"):
+		format := s[5:]
+		if !strings.Contains(format, "%d") && !strings.Contains(format, "%v") {
+			return nil, p.errorf(node, "invalid scan format %q", format)
+		}
+		return export.ScanInt(format), nil
+	case strings.HasPrefix(s, "attr:") && len(s) > 5:
+		return export.AttrVal(s[5:]), nil
+	}
+	return nil, p.errorf(node, "unknown conv %q", s)
+}
+
+func (p *parser) paginate(node *yaml.Node) (ret *paginate, err error) {
+	ret = new(paginate)
+	err = p.mapping(node, func(key string, k, v *yaml.Node) (err error) {
+		switch key {
+		case "next":
+			ret.next, err = p.selector(v)
+		case "template":
+			if ret.template, err = p.str(v); err == nil && !strings.Contains(ret.template, "%d") {
+				err = p.errorf(v, "template without %%d")
+			}
+		case "max_pages":
+			ret.opts.MaxPages, err = p.int(v)
+		case "delay":
+			var s string
+			if s, err = p.str(v); err == nil {
+				if ret.opts.Delay, err = time.ParseDuration(s); err != nil {
+					err = p.errorf(v, "invalid delay: %v", err)
+				}
+			}
+		default:
+			err = p.errorf(k, "unknown key %q", key)
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	if (ret.next == nil) == (ret.template == "") {
+		return nil, p.errorf(node, "paginate requires one of next and template")
+	}
+	return
+}
+
+func (p *parser) output(node *yaml.Node, o *output) error {
+	return p.mapping(node, func(key string, k, v *yaml.Node) (err error) {
+		switch key {
+		case "format":
+			if o.format, err = p.str(v); err == nil && newWriters[o.format] == nil {
+				err = p.errorf(v, "unknown output format %q", o.format)
+			}
+		case "file":
+			o.file, err = p.str(v)
+		case "on_error":
+			var s string
+			if s, err = p.str(v); err != nil {
+				return
+			}
+			policy, ok := policies[s]
+			if !ok {
+				return p.errorf(v, "unknown on_error %q", s)
+			}
+			o.policy = policy
+		case "header":
+			var s string
+			if s, err = p.str(v); err == nil {
+				switch s {
+				case "true":
+				case "false":
+					o.noHeader = true
+				default:
+					err = p.errorf(v, "expect true or false")
+				}
+			}
+		default:
+			err = p.errorf(k, "unknown key %q", key)
+		}
+		return
+	})
+}
+
+// -----------------------------------------------------------------------------
+
+type step = func(ns hq.NodeSet) hq.NodeSet
+
+var keywordSteps = map[string]step{
+	"any":                 func(ns hq.NodeSet) hq.NodeSet { return ns.Any() },
+	"any_outermost":       func(ns hq.NodeSet) hq.NodeSet { return ns.Any(hq.AnyOutermost) },
+	"any_innermost":       func(ns hq.NodeSet) hq.NodeSet { return ns.Any(hq.AnyInnermost) },
+	"child":               hq.NodeSet.Child,
+	"parent":              hq.NodeSet.Parent,
+	"one":                 hq.NodeSet.One,
+	"first_element_child": hq.NodeSet.FirstElementChild,
+	"last_element_child":  hq.NodeSet.LastElementChild,
+	"first_text_child":    hq.NodeSet.FirstTextChild,
+	"last_text_child":     hq.NodeSet.LastTextChild,
+	"next_siblings":       hq.NodeSet.NextSiblings,
+	"prev_siblings":       hq.NodeSet.PrevSiblings,
+}
+
+var strSteps = map[string]func(ns hq.NodeSet, v string) hq.NodeSet{
+	"element":          func(ns hq.NodeSet, v string) hq.NodeSet { return ns.Element(v) },
+	"class":            hq.NodeSet.ContainsClass,
+	"id":               hq.NodeSet.ID,
+	"href":             hq.NodeSet.Href,
+	"text":             hq.NodeSet.ContainsText,
+	"equal_text":       hq.NodeSet.EqualText,
+	"child_equal_text": hq.NodeSet.ChildEqualText,
+}
+
+var intSteps = map[string]func(ns hq.NodeSet, v int) hq.NodeSet{
+	"child_n":      hq.NodeSet.ChildN,
+	"parent_n":     hq.NodeSet.ParentN,
+	"next_sibling": hq.NodeSet.NextSibling,
+	"prev_sibling": hq.NodeSet.PrevSibling,
+}
+
+// selector parses a list of steps. A step is a keyword (eg. any, child), an
+// element tag (eg. div), or a mapping with one key (eg. `class: item`,
+// `attr: href=/`, `child_n: 2`).
+func (p *parser) selector(node *yaml.Node) (sel func(ns hq.NodeSet) hq.NodeSet, err error) {
+	nodes := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		nodes = node.Content
+	}
+	if len(nodes) == 0 {
+		return nil, p.errorf(node, "empty selector")
+	}
+	steps := make([]step, len(nodes))
+	for i, item := range nodes {
+		if steps[i], err = p.step(item); err != nil {
+			return
+		}
+	}
+	return func(ns hq.NodeSet) hq.NodeSet {
+		for _, step := range steps {
+			ns = step(ns)
+		}
+		return ns
+	}, nil
+}
+
+func (p *parser) step(node *yaml.Node) (ret step, err error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if step, ok := keywordSteps[node.Value]; ok {
+			return step, nil
+		}
+		if !isTag(node.Value) {
+			return nil, p.errorf(node, "invalid step %q", node.Value)
+		}
+		tag := node.Value
+		return func(ns hq.NodeSet) hq.NodeSet { return ns.Element(tag) }, nil
+	case yaml.MappingNode:
+		if len(node.Content) != 2 {
+			return nil, p.errorf(node, "expect a mapping with one key")
+		}
+		k, v := node.Content[0], node.Content[1]
+		if fn, ok := strSteps[k.Value]; ok {
+			s, err := p.str(v)
+			if err != nil {
+				return nil, err
+			}
+			return func(ns hq.NodeSet) hq.NodeSet { return fn(ns, s) }, nil
+		}
+		if fn, ok := intSteps[k.Value]; ok {
+			n, err := p.int(v)
+			if err != nil {
+				return nil, err
+			}
+			return func(ns hq.NodeSet) hq.NodeSet { return fn(ns, n) }, nil
+		}
+		if k.Value == "attr" {
+			s, err := p.str(v)
+			if err != nil {
+				return nil, err
+			}
+			pos := strings.IndexByte(s, '=')
+			if pos <= 0 {
+				return nil, p.errorf(v, "expect attr: name=value")
+			}
+			name, val := s[:pos], s[pos+1:]
+			return func(ns hq.NodeSet) hq.NodeSet { return ns.Attribute(name, val) }, nil
+		}
+		return nil, p.errorf(k, "unknown step %q", k.Value)
+	}
+	return nil, p.errorf(node, "invalid step")
+}
+
+func isTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && (c >= '0' && c <= '9' || c == '-')) {
+			return false
+		}
+	}
+	return true
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,129 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package rules implements declarative scraping rules. A rule file (in YAML
+// or JSON) looks like:
+//
+//	sources:
+//	  - https://example.com/list?page=1
+//	items: [any, div, class: item]
+//	fields:
+//	  - name: title
+//	    select: [any, a]
+//	  - name: link
+//	    select: [any, a]
+//	    conv: attr:href
+//	  - name: stars
+//	    select: [any, span, class: stars]
+//	    conv: unitedfloat
+//	  - name: count
+//	    select: [any, span, class: count]
+//	    conv: scan:%d items
+//	paginate:
+//	  next: [any, a, class: next]
+//	  max_pages: 10
+//	  delay: 1s
+//	output:
+//	  format: csv     # csv, tsv, jsonl or json
+//	  file: out.csv   # stdout if not set
+//	  on_error: skip  # fail, skip or null
+package rules
+
+import (
+	"io"
+	"os"
+
+	"github.com/qiniu/goplus-dt/export"
+	"github.com/qiniu/goplus-dt/hq"
+)
+
+// -----------------------------------------------------------------------------
+
+var newWriters = map[string]func(w io.Writer) export.RowWriter{
+	"csv":   export.NewCSVWriter,
+	"tsv":   export.NewTSVWriter,
+	"jsonl": export.NewJSONLinesWriter,
+	"json":  export.NewJSONArrayWriter,
+}
+
+var policies = map[string]export.ErrorPolicy{
+	"fail": export.Fail,
+	"skip": export.SkipRow,
+	"null": export.Null,
+}
+
+type paginate struct {
+	next     func(ns hq.NodeSet) hq.NodeSet
+	template string
+	opts     hq.PageOptions
+}
+
+type output struct {
+	format   string
+	file     string
+	policy   export.ErrorPolicy
+	noHeader bool
+}
+
+// Rules - compiled scraping rules.
+type Rules struct {
+	Sources []string
+
+	items    func(doc hq.NodeSet) hq.NodeSet
+	cols     []export.Column
+	paginate *paginate
+	output   output
+}
+
+// Items returns items of all sources (of all pages if paginated) as a node set.
+func (p *Rules) Items(src hq.SourceCreator) (ret hq.NodeSet) {
+	docs := make([]hq.NodeSet, len(p.Sources))
+	for i, uri := range p.Sources {
+		if pg := p.paginate; pg != nil {
+			var next hq.NextPage
+			if pg.next != nil {
+				next = hq.NextLink(pg.next)
+			} else {
+				next = hq.PageTemplate(pg.template, p.items)
+			}
+			docs[i] = src.Paginate(uri, next, &pg.opts)
+		} else {
+			docs[i] = src.URI(uri)
+		}
+	}
+	return p.items(hq.Concat(docs...))
+}
+
+// Run runs the rules and writes items to the output specified by the rules.
+// If w isn't nil, it overrides the output file. Errors of fetching sources
+// and pages are returned, after the rows written before them.
+func (p *Rules) Run(src hq.SourceCreator, w io.Writer) (rows int, err error) {
+	if w == nil {
+		w = os.Stdout
+		if p.output.file != "" {
+			f, err := os.Create(p.output.file)
+			if err != nil {
+				return 0, err
+			}
+			defer f.Close()
+			w = f
+		}
+	}
+	opts := &export.Options{Policy: p.output.policy, NoHeader: p.output.noHeader}
+	return export.Write(newWriters[p.output.format](w), p.Items(src), p.cols, opts)
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,132 @@
+/*
+ Copyright 2020 Qiniu Cloud (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/goplus-dt/hq"
+)
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		rules, want string
+	}{
+		{"source: a\nitems: [any, div]\nfields:\n  - name: x\n    select: [any, a\n",
+			"r.yml:4: did not find expected ',' or ']'"},
+		{"source: a\n items: b\nc", "r.yml:2: mapping values are not allowed in this context"},
+		{"", "r.yml:1: empty rule file"},
+		{"- a", "r.yml:1:1: expect a mapping"},
+		{"source: a\nitems: [any, div]\n", "r.yml:1:1: no fields"},
+		{"source: a\nitems: [any, div]\nfield: []\n", "r.yml:3:1: unknown key \"field\""},
+		{"source: a\nitems: [any, 'a b']\nfields: [{name: x}]\n", "r.yml:2:14: invalid step \"a b\""},
+		{"source: a\nitems: [any, {child_n: x}]\nfields: [{name: x}]\n", "r.yml:2:24: expect an integer"},
+		{"source: a\nitems: [a]\nfields: [{name: x, conv: scan:x}]\n", "r.yml:3:26: invalid scan format \"x\""},
+		{"source: a\nitems: [a]\nfields: [{name: x}, {name: x}]\n", "r.yml:3:21: duplicated field \"x\""},
+		{"source: a\nitems: [a]\nfields: [{name: x}]\npaginate: {max_pages: 2}\n", "r.yml:4:11: paginate requires one of next and template"},
+		{"source: a\nitems: [a]\nfields: [{name: x}]\noutput: {format: xml}\n", "r.yml:4:18: unknown output format \"xml\""},
+	}
+	for _, c := range cases {
+		_, err := Parse([]byte(c.rules), "r.yml")
+		if err == nil || err.Error() != c.want {
+			t.Errorf("Parse(%q) = %v, want %s", c.rules, err, c.want)
+		}
+	}
+}
+
+func newListServer(pages int, last string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		fmt.Fprint(w, `<div class="list">`)
+		for i := 1; i <= 2; i++ {
+			n := page*10 + i
+			fmt.Fprintf(w, `<div class="item"><a href="/item/%d">item %d</a><span class="count">%d items</span></div>`, n, n, n)
+		}
+		fmt.Fprint(w, `</div>`)
+		switch {
+		case page < pages:
+			fmt.Fprintf(w, `<a class="next" href="?page=%d">next</a>`, page+1)
+		case last != "":
+			fmt.Fprintf(w, `<a class="next" href="%s">next</a>`, last)
+		}
+	}))
+}
+
+const listRules = `
+sources:
+  - %s/?page=1
+items: [any, div, class: item]
+fields:
+  - name: title
+    select: [any, a]
+  - name: link
+    select: [any, a]
+    conv: attr:href
+  - name: count
+    select: [any, span, class: count]
+    conv: scan:%%d items
+paginate:
+  next: [any, a, class: next]
+  max_pages: 5
+output:
+  format: csv
+`
+
+func TestRun(t *testing.T) {
+	srv := newListServer(3, "")
+	defer srv.Close()
+
+	r, err := Parse([]byte(fmt.Sprintf(listRules, srv.URL)), "list.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	rows, err := r.Run(hq.Source, &b)
+	if err != nil || rows != 6 {
+		t.Fatal(rows, err)
+	}
+	want := "title,link,count\n" +
+		"item 11,/item/11,11\nitem 12,/item/12,12\n" +
+		"item 21,/item/21,21\nitem 22,/item/22,22\n" +
+		"item 31,/item/31,31\nitem 32,/item/32,32\n"
+	if b.String() != want {
+		t.Fatalf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestRunPageError(t *testing.T) {
+	srv := newListServer(2, "http://127.0.0.1:1/")
+	defer srv.Close()
+
+	r, err := Parse([]byte(fmt.Sprintf(listRules, srv.URL)), "list.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	rows, err := r.Run(hq.Source, &b)
+	if err == nil || rows != 4 {
+		t.Fatal("a failed page isn't reported:", rows, err)
+	}
+	if strings.Count(b.String(), "\n") != 5 {
+		t.Fatal("rows before the failed page aren't written:", b.String())
+	}
+}